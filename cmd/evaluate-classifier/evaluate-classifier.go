@@ -0,0 +1,67 @@
+// evaluate-classifier: CLI to measure the giveaway classifier on the posts
+// labelled with the 👍/👎 buttons of the notifications.
+// Usage of evaluate-classifier:
+//   -db string
+//         Path to the database file of the bot (required, the bot must be stopped)
+//   -folds int
+//         Number of folds of the cross validation of the feedback model (default 5)
+//   -rules string
+//         Path to a JSON file of rules to classify the giveaways (default rules if not set)
+// The rules are evaluated alone, then mixed with the feedback model by cross
+// validation so that no post is classified by a model trained on its label.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/maxime915/mk-giveaway-notifier/giveaway"
+	"github.com/maxime915/mk-giveaway-notifier/telegram"
+)
+
+func main() {
+	path := flag.String("db", "", "Path to the database file of the bot (required, the bot must be stopped)")
+	folds := flag.Int("folds", 5, "Number of folds of the cross validation of the feedback model")
+	rules := flag.String("rules", "", "Path to a JSON file of rules to classify the giveaways (default rules if not set)")
+	flag.Parse()
+
+	if len(*path) == 0 {
+		log.Fatal("database file is required")
+	}
+	if *folds < 2 {
+		log.Fatal("folds must be at least 2")
+	}
+
+	classifier := giveaway.DefaultClassifier()
+	if len(*rules) > 0 {
+		var err error
+		classifier, err = giveaway.LoadClassifier(*rules)
+		if err != nil {
+			log.Fatalf("unable to load the rules: %s\n", err.Error())
+		}
+	}
+
+	examples, err := telegram.LoadLabels(*path)
+	if err != nil {
+		log.Fatalf("unable to read the labels: %s\n", err.Error())
+	}
+
+	giveaways := 0
+	for _, example := range examples {
+		if example.Giveaway {
+			giveaways++
+		}
+	}
+	fmt.Printf("%d labelled posts, %d giveaways\n", len(examples), giveaways)
+	if len(examples) == 0 {
+		return
+	}
+
+	fmt.Printf("rules:         %v\n", giveaway.Evaluate(classifier, examples))
+	fmt.Printf("rules + model: %v\n", giveaway.CrossValidate(classifier, examples, *folds))
+
+	if len(examples)-giveaways < giveaway.MinExamples || giveaways < giveaway.MinExamples {
+		fmt.Printf("the model needs at least %d posts of each kind, it is not used yet\n", giveaway.MinExamples)
+	}
+}
@@ -1,26 +1,128 @@
 // start-bot: CLI to launch the telegram & reddit bots.
 // Usage of start-bot:
-//   -path string
-//         Path to load/save the configuration of the bot: if token is given this file will be erased (default "telegram_state.private.json")
+//   -cache-ttl duration
+//         Duration for which the posts fetched for a set of subreddits are shared by the feeds without calling reddit (default 5m0s)
+//   -db string
+//         Path to the database file (required, will be created if file doesn't exist)
+//   -fake-reddit string
+//         Path to a script of posts to play back instead of calling the reddit API
+//   -interval duration
+//         Default interval between two automatic updates of a feed (default 15m0s)
+//   -jitter duration
+//         Random variation around the interval of the automatic updates (default 2m0s)
+//   -reddit-id string
+//         Client id of the reddit script application (or $GO_REDDIT_CLIENT_ID)
+//   -reddit-password string
+//         Password of the reddit account (or $GO_REDDIT_CLIENT_PASSWORD)
+//   -reddit-secret string
+//         Client secret of the reddit script application (or $GO_REDDIT_CLIENT_SECRET)
+//   -reddit-username string
+//         Username of the reddit account (or $GO_REDDIT_CLIENT_USERNAME)
+//   -rules string
+//         Path to a JSON file of rules to classify the giveaways (default rules if not set)
+//   -seen-ttl duration
+//         Duration for which the posts sent to a chat are remembered to avoid duplicates (default 336h0m0s)
+//   -sinks-allow-private
+//         Let the sinks configured by the users connect to loopback, private and link-local addresses
+//   -telegram-api string
+//         Base URL of the Telegram Bot API, e.g. of a self-hosted server (default "https://api.telegram.org")
+//   -telegram-proxy string
+//         URL of the proxy to the Telegram Bot API (default $HTTPS_PROXY)
+//   -telegram-timeout duration
+//         Timeout of the requests to the Telegram Bot API, longer than the 30s long polls (default 1m0s)
 //   -token string
-//         Telegram bot token: if given, a new bot is created and the path is only used to save the configuration
+//         Telegram token (required)
+//   -webhook string
+//         Public HTTPS URL of the webhook receiving the updates (long polling if not set)
+//   -webhook-cert string
+//         Path to the TLS certificate of the webhook listener (plain HTTP if not set)
+//   -webhook-key string
+//         Path to the TLS key of the webhook listener
+//   -webhook-listen string
+//         Address of the webhook listener (default ":8443")
+//   -webhook-secret string
+//         Secret token checked on the updates of the webhook (or $TELEGRAM_WEBHOOK_SECRET, random if not set)
+// Without any reddit credentials, the reddit API is used anonymously.
 package main
 
 import (
 	"flag"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+	// the deadlines in "ET", "PT", ... need the time zone database, embedded
+	// for the hosts without one
+	_ "time/tzdata"
 
+	"github.com/maxime915/mk-giveaway-notifier/giveaway"
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	"github.com/maxime915/mk-giveaway-notifier/sink"
 	"github.com/maxime915/mk-giveaway-notifier/telegram"
 )
 
+// flagOrEnv returns the value of the flag if set, the value of the environment
+// variable otherwise
+func flagOrEnv(value *string, env string) string {
+	if len(*value) > 0 {
+		return *value
+	}
+	return os.Getenv(env)
+}
+
+// redditBot returns a fake bot if a script is given, an authenticated bot if
+// any credentials are given, the anonymous bot otherwise
+func redditBot(script string, credentials reddit.Credentials) (reddit.Fetcher, error) {
+	if len(script) > 0 {
+		return reddit.LoadFakeBot(script)
+	}
+	if credentials == (reddit.Credentials{}) {
+		return reddit.DefaultBot(), nil
+	}
+	return reddit.NewRedditBotWithCredentials(credentials)
+}
+
+// telegramClient returns the HTTP client of the requests to Telegram, using
+// the proxy of the environment if `proxy` is empty
+func telegramClient(timeout time.Duration, proxy string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(proxy) > 0 {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
+
 func main() {
 	log.SetFlags(log.LstdFlags | log.Llongfile)
 
 	token := flag.String("token", "", "Telegram token (required)")
 	path := flag.String("db", "", "Path to the database file (required, will be created if file doesn't exist)")
+	interval := flag.Duration("interval", telegram.DefaultPollInterval, "Default interval between two automatic updates of a feed")
+	jitter := flag.Duration("jitter", telegram.DefaultPollJitter, "Random variation around the interval of the automatic updates")
+	redditID := flag.String("reddit-id", "", "Client id of the reddit script application (or $GO_REDDIT_CLIENT_ID)")
+	redditSecret := flag.String("reddit-secret", "", "Client secret of the reddit script application (or $GO_REDDIT_CLIENT_SECRET)")
+	redditUsername := flag.String("reddit-username", "", "Username of the reddit account (or $GO_REDDIT_CLIENT_USERNAME)")
+	redditPassword := flag.String("reddit-password", "", "Password of the reddit account (or $GO_REDDIT_CLIENT_PASSWORD)")
+	seenTTL := flag.Duration("seen-ttl", telegram.DefaultSeenTTL, "Duration for which the posts sent to a chat are remembered to avoid duplicates")
+	fakeReddit := flag.String("fake-reddit", "", "Path to a script of posts to play back instead of calling the reddit API")
+	cacheTTL := flag.Duration("cache-ttl", telegram.DefaultCacheTTL, "Duration for which the posts fetched for a set of subreddits are shared by the feeds without calling reddit")
+	rules := flag.String("rules", "", "Path to a JSON file of rules to classify the giveaways (default rules if not set)")
+	sinksAllowPrivate := flag.Bool("sinks-allow-private", false, "Let the sinks configured by the users connect to loopback, private and link-local addresses")
+	telegramAPI := flag.String("telegram-api", telegram.DefaultAPIURL, "Base URL of the Telegram Bot API, e.g. of a self-hosted server")
+	telegramProxy := flag.String("telegram-proxy", "", "URL of the proxy to the Telegram Bot API (default $HTTPS_PROXY)")
+	telegramTimeout := flag.Duration("telegram-timeout", time.Minute, "Timeout of the requests to the Telegram Bot API, longer than the 30s long polls")
+	webhookURL := flag.String("webhook", "", "Public HTTPS URL of the webhook receiving the updates (long polling if not set)")
+	webhookListen := flag.String("webhook-listen", ":8443", "Address of the webhook listener")
+	webhookSecret := flag.String("webhook-secret", "", "Secret token checked on the updates of the webhook (or $TELEGRAM_WEBHOOK_SECRET, random if not set)")
+	webhookCert := flag.String("webhook-cert", "", "Path to the TLS certificate of the webhook listener (plain HTTP if not set)")
+	webhookKey := flag.String("webhook-key", "", "Path to the TLS key of the webhook listener")
 	flag.Parse()
 
 	if len(*path) == 0 {
@@ -29,6 +131,24 @@ func main() {
 	if len(*token) == 0 {
 		log.Fatal("telegram token is required")
 	}
+	if *interval < telegram.MinPollInterval {
+		log.Fatalf("interval must be at least %v", telegram.MinPollInterval)
+	}
+	if *jitter < 0 {
+		log.Fatal("jitter must not be negative")
+	}
+	if *seenTTL <= 0 {
+		log.Fatal("seen-ttl must be positive")
+	}
+	if *cacheTTL < 0 {
+		log.Fatal("cache-ttl must not be negative")
+	}
+	if *telegramTimeout <= 0 {
+		log.Fatal("telegram-timeout must be positive")
+	}
+	if len(*webhookURL) == 0 && *telegramTimeout <= telegram.LongPollTimeout {
+		log.Fatalf("telegram-timeout must be longer than %v", telegram.LongPollTimeout)
+	}
 
 	// listen to interrupts
 	interrupted := make(chan struct{})
@@ -40,10 +160,50 @@ func main() {
 	}()
 
 	// bot creation
-	bot, err := telegram.NewTelegramNotifier(*token, *path)
+	rBot, err := redditBot(*fakeReddit, reddit.Credentials{
+		ID:       flagOrEnv(redditID, "GO_REDDIT_CLIENT_ID"),
+		Secret:   flagOrEnv(redditSecret, "GO_REDDIT_CLIENT_SECRET"),
+		Username: flagOrEnv(redditUsername, "GO_REDDIT_CLIENT_USERNAME"),
+		Password: flagOrEnv(redditPassword, "GO_REDDIT_CLIENT_PASSWORD"),
+	})
+	if err != nil {
+		log.Fatalf("unable to create the reddit bot: %s\n", err.Error())
+	}
+
+	sink.AllowPrivateNetworks = *sinksAllowPrivate
+
+	classifier := giveaway.DefaultClassifier()
+	if len(*rules) > 0 {
+		classifier, err = giveaway.LoadClassifier(*rules)
+		if err != nil {
+			log.Fatalf("unable to load the rules: %s\n", err.Error())
+		}
+	}
+
+	client, err := telegramClient(*telegramTimeout, *telegramProxy)
+	if err != nil {
+		log.Fatalf("invalid proxy: %s\n", err.Error())
+	}
+	options := telegram.Options{URL: *telegramAPI, Client: client}
+	if len(*webhookURL) > 0 {
+		options.Poller = &telegram.Webhook{
+			Listen:      *webhookListen,
+			PublicURL:   *webhookURL,
+			SecretToken: flagOrEnv(webhookSecret, "TELEGRAM_WEBHOOK_SECRET"),
+			TLSCert:     *webhookCert,
+			TLSKey:      *webhookKey,
+		}
+	}
+
+	bot, err := telegram.NewTelegramNotifierWithOptions(*token, *path, rBot, options)
 	if err != nil {
 		log.Fatalf("unable to start: %s\nIf you are online, verify the token\n", err.Error())
 	}
+	bot.PollInterval = *interval
+	bot.PollJitter = *jitter
+	bot.SeenTTL = *seenTTL
+	bot.CacheTTL = *cacheTTL
+	bot.Classifier = classifier
 
 	// start telegram bot
 	done := make(chan struct{})
@@ -55,11 +215,12 @@ func main() {
 		close(done)
 	}()
 
-	// when interrupted, stop and wait until done
+	// when interrupted, stop (cancelling the calls to reddit) and wait until done
 	// when done, proceed
 	select {
 	case <-interrupted:
 		bot.Stop()
+		<-done
 	case <-done:
 	}
 }
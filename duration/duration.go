@@ -0,0 +1,31 @@
+// duration parses the durations written by the users, which may count days
+// and weeks on top of the units of time.ParseDuration.
+package duration
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// units are the units accepted by Parse on top of the ones of
+// time.ParseDuration
+var units = map[byte]time.Duration{
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// Parse parses a duration as time.ParseDuration does, with the addition of
+// days and weeks for single unit durations (e.g. "2d", "1w").
+func Parse(s string) (time.Duration, error) {
+	if len(s) > 1 {
+		if unit, ok := units[s[len(s)-1]]; ok {
+			count, err := strconv.ParseFloat(s[:len(s)-1], 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			return time.Duration(count * float64(unit)), nil
+		}
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,21 @@
+package duration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	d, err := Parse("2d")
+	assert.Nil(t, err)
+	assert.Equal(t, 48*time.Hour, d)
+
+	d, err = Parse("1h30m")
+	assert.Nil(t, err)
+	assert.Equal(t, 90*time.Minute, d)
+
+	_, err = Parse("1d2h")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,165 @@
+// filter implements a small boolean language to select reddit posts, e.g.
+//
+//	giveaway or (title contains "GMK" and flair = "Photos")
+//
+// Predicates:
+//
+//	giveaway                          the post is classified as a giveaway
+//	title|selftext|flair|author OP v  OP is =, != (case insensitive), contains,
+//	                                  ~ or matches (regular expression), !~
+//	score OP n                        OP is =, !=, <, <=, > or >=
+//	age OP d                          d is a duration (e.g. 30m, 6h, 2d, 1w)
+//
+// Predicates are combined with not, and, or (from the highest to the lowest
+// precedence) and parentheses. Values are "double quoted", 'single quoted',
+// /a regex/ or bare words.
+package filter
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+)
+
+// Env is the context in which a filter is evaluated
+type Env struct {
+	// IsGiveaway is the classifier used by the `giveaway` predicate
+	IsGiveaway func(*reddit.Post) bool
+	// Now is the reference time of the `age` predicate
+	Now time.Time
+}
+
+// Filter is a parsed filter expression
+type Filter struct {
+	source string
+	root   node
+}
+
+// Parse parses a filter expression. The error is a *SyntaxError if the
+// expression is invalid.
+func Parse(expr string) (*Filter, error) {
+	p, err := newParser(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Filter{source: strings.TrimSpace(expr), root: root}, nil
+}
+
+// Match returns true if `post` is selected by the filter
+func (f *Filter) Match(post *reddit.Post, env Env) bool {
+	return f.root.eval(post, &env)
+}
+
+// String returns the source of the filter
+func (f *Filter) String() string {
+	return f.source
+}
+
+// node is a node of the syntax tree of a filter
+type node interface {
+	eval(post *reddit.Post, env *Env) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(post *reddit.Post, env *Env) bool {
+	return n.left.eval(post, env) && n.right.eval(post, env)
+}
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(post *reddit.Post, env *Env) bool {
+	return n.left.eval(post, env) || n.right.eval(post, env)
+}
+
+type notNode struct{ operand node }
+
+func (n notNode) eval(post *reddit.Post, env *Env) bool {
+	return !n.operand.eval(post, env)
+}
+
+type giveawayNode struct{}
+
+func (giveawayNode) eval(post *reddit.Post, env *Env) bool {
+	return env.IsGiveaway != nil && env.IsGiveaway(post)
+}
+
+// textFields are the text fields of a post usable in a filter
+var textFields = map[string]func(*reddit.Post) string{
+	"title":    func(post *reddit.Post) string { return post.Title },
+	"selftext": func(post *reddit.Post) string { return post.Body },
+	"flair":    func(post *reddit.Post) string { return post.Flair },
+	"author":   func(post *reddit.Post) string { return post.Author },
+}
+
+type textNode struct {
+	field func(*reddit.Post) string
+	op    string
+	value string
+	rx    *regexp.Regexp
+}
+
+func (n textNode) eval(post *reddit.Post, env *Env) bool {
+	text := n.field(post)
+	switch n.op {
+	case "=":
+		return strings.EqualFold(text, n.value)
+	case "!=":
+		return !strings.EqualFold(text, n.value)
+	case "contains":
+		return strings.Contains(strings.ToLower(text), strings.ToLower(n.value))
+	case "~":
+		return n.rx.MatchString(text)
+	case "!~":
+		return !n.rx.MatchString(text)
+	}
+	return false
+}
+
+// compare applies a comparison operator
+func compare(op string, a, b int64) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+type scoreNode struct {
+	op    string
+	value int64
+}
+
+func (n scoreNode) eval(post *reddit.Post, env *Env) bool {
+	return compare(n.op, int64(post.Score), n.value)
+}
+
+type ageNode struct {
+	op    string
+	value time.Duration
+}
+
+func (n ageNode) eval(post *reddit.Post, env *Env) bool {
+	if post.Created == nil {
+		return false
+	}
+	return compare(n.op, int64(env.Now.Sub(post.Created.Time)), int64(n.value))
+}
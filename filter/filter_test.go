@@ -0,0 +1,88 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	"github.com/stretchr/testify/assert"
+	goreddit "github.com/vartanbeno/go-reddit/v2/reddit"
+)
+
+func testPost(title, flair, body, author string, score int, created time.Time) *reddit.Post {
+	post := &reddit.Post{Flair: flair}
+	post.Title = title
+	post.Body = body
+	post.Author = author
+	post.Score = score
+	post.Created = &goreddit.Timestamp{Time: created}
+	return post
+}
+
+func TestMatch(t *testing.T) {
+	now := time.Now()
+	env := Env{
+		IsGiveaway: func(post *reddit.Post) bool { return strings.Contains(post.Title, "Giveaway") },
+		Now:        now,
+	}
+
+	giveaway := testPost("Keycaps Giveaway", "", "comment to enter", "someone", 12, now.Add(-time.Hour))
+	photo := testPost("My GMK Olivia build", "Photos", "", "Other_One", 250, now.Add(-3*24*time.Hour))
+
+	cases := []struct {
+		expr             string
+		giveaway, photos bool
+	}{
+		{`giveaway`, true, false},
+		{`giveaway or (title contains "GMK" and flair = 'photos')`, true, true},
+		{`not giveaway`, false, true},
+		{`title ~ /olivia/i`, false, true},
+		{`title matches "olivia"`, false, false},
+		{`title !~ /olivia/i and not author = OTHER_ONE`, true, false},
+		{`selftext contains enter`, true, false},
+		{`flair != photos`, true, false},
+		{`score >= 100`, false, true},
+		{`score < 20 AND age <= 2h`, true, false},
+		{`age > 2d`, false, true},
+		{`not giveaway and not (score > 200)`, false, false},
+		{`giveaway or score > 100 and age < 1d`, true, false},
+	}
+
+	for _, c := range cases {
+		f, err := Parse(c.expr)
+		if !assert.Nil(t, err, c.expr) {
+			continue
+		}
+		assert.Equal(t, c.giveaway, f.Match(giveaway, env), c.expr)
+		assert.Equal(t, c.photos, f.Match(photo, env), c.expr)
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	cases := map[string]string{
+		``:                       "empty filter at column 1",
+		`title contains`:         "expected a value, got end of filter at column 15",
+		`titl = "GMK"`:           `unknown predicate "titl", expected giveaway, title, selftext, flair, author, score or age at column 1`,
+		`(giveaway or score > 2`: "missing closing parenthesis at column 1",
+		`giveaway)`:              "unbalanced parenthesis at column 9",
+		`score > many`:           "score must be compared to an integer at column 9",
+		`age < soon`:             "age must be compared to a duration (e.g. 30m, 6h, 2d) at column 7",
+		`title ~ "("`:            "invalid regular expression: error parsing regexp: missing closing ): `(` at column 9",
+		`title = /gmk/`:          "a regular expression requires ~ or matches at column 9",
+		`title is "GMK"`:         `expected an operator after title (== != !~ = ~ contains matches), got "is" at column 7`,
+		`giveaway title`:         `expected and, or or the end of the filter, got "title" at column 10`,
+		`flair = "Photos`:        "unterminated value at column 9",
+		`giveaway & score > 2`:   "unexpected character '&' at column 10",
+	}
+
+	for expr, message := range cases {
+		_, err := Parse(expr)
+		if assert.IsType(t, &SyntaxError{}, err, expr) {
+			assert.Equal(t, message, err.Error(), expr)
+		}
+	}
+
+	_, err := Parse(`title contains`)
+	assert.Equal(t, "title contains\n              ^", err.(*SyntaxError).Pointer())
+}
@@ -0,0 +1,392 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/maxime915/mk-giveaway-notifier/duration"
+)
+
+// SyntaxError is an error in a filter expression
+type SyntaxError struct {
+	Expr string
+	// Pos is the byte offset of the error in Expr
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at column %d", e.Msg, utf8.RuneCountInString(e.Expr[:e.Pos])+1)
+}
+
+// Pointer returns the expression with a caret under the error on the next
+// line, to be displayed in a monospace font
+func (e *SyntaxError) Pointer() string {
+	return e.Expr + "\n" + strings.Repeat(" ", utf8.RuneCountInString(e.Expr[:e.Pos])) + "^"
+}
+
+type tokenKind int
+
+const (
+	tEOF tokenKind = iota
+	tWord
+	tString
+	tRegex
+	tOperator
+	tLParen
+	tRParen
+)
+
+type token struct {
+	kind tokenKind
+	// text is the value of the token (unquoted for strings and regexes)
+	text string
+	pos  int
+}
+
+// describe returns the token as displayed in an error
+func (t token) describe() string {
+	if t.kind == tEOF {
+		return "end of filter"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// operators are the operators, longest first
+var operators = []string{"==", "!=", "!~", "<=", ">=", "=", "~", "<", ">"}
+
+// isWordRune returns true for the characters of a bare word
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.:-+", r)
+}
+
+// lex splits an expression into tokens
+func lex(expr string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(expr); {
+		r, size := utf8.DecodeRuneInString(expr[i:])
+
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+			continue
+		case r == '(':
+			tokens = append(tokens, token{tLParen, "(", i})
+			i++
+			continue
+		case r == ')':
+			tokens = append(tokens, token{tRParen, ")", i})
+			i++
+			continue
+		case r == '"' || r == '\'' || r == '/':
+			end, ok := closing(expr, i)
+			if !ok {
+				return nil, &SyntaxError{expr, i, "unterminated value"}
+			}
+			tok, err := quoted(expr, i, end)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			i = end + 1
+			// flag of a case insensitive regex
+			if r == '/' && i < len(expr) && expr[i] == 'i' && (i+1 == len(expr) || !isWordRune(rune(expr[i+1]))) {
+				tokens[len(tokens)-1].text = "(?i)" + tokens[len(tokens)-1].text
+				i++
+			}
+			continue
+		case isWordRune(r):
+			start := i
+			for i < len(expr) {
+				r, size := utf8.DecodeRuneInString(expr[i:])
+				if !isWordRune(r) {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, token{tWord, expr[start:i], start})
+			continue
+		}
+
+		found := false
+		for _, op := range operators {
+			if strings.HasPrefix(expr[i:], op) {
+				tokens = append(tokens, token{tOperator, op, i})
+				i += len(op)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, &SyntaxError{expr, i, fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+
+	return append(tokens, token{tEOF, "", len(expr)}), nil
+}
+
+// closing returns the index of the quote closing the one at `start`, a quote
+// preceded by a backslash does not close the value
+func closing(expr string, start int) (int, bool) {
+	quote := expr[start]
+	for i := start + 1; i < len(expr); i++ {
+		switch expr[i] {
+		case '\\':
+			i++
+		case quote:
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// quoted returns the token of the quoted value expr[start:end+1]
+func quoted(expr string, start, end int) (token, error) {
+	raw := expr[start+1 : end]
+	switch expr[start] {
+	case '"':
+		text, err := strconv.Unquote(expr[start : end+1])
+		if err != nil {
+			return token{}, &SyntaxError{expr, start, "invalid string"}
+		}
+		return token{tString, text, start}, nil
+	case '\'':
+		return token{tString, strings.ReplaceAll(raw, `\'`, `'`), start}, nil
+	default:
+		return token{tRegex, strings.ReplaceAll(raw, `\/`, `/`), start}, nil
+	}
+}
+
+// parser is a recursive descent parser of the expression
+//
+//	expr      = and { "or" and }
+//	and       = unary { "and" unary }
+//	unary     = "not" unary | "(" expr ")" | predicate
+//	predicate = "giveaway" | field operator value
+type parser struct {
+	expr   string
+	tokens []token
+	next   int
+}
+
+func newParser(expr string) (*parser, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{expr: expr, tokens: tokens}, nil
+}
+
+// peek returns the current token
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+// advance returns the current token and moves to the next one
+func (p *parser) advance() token {
+	tok := p.tokens[p.next]
+	if tok.kind != tEOF {
+		p.next++
+	}
+	return tok
+}
+
+// keyword returns true if the current token is the keyword `word`
+func (p *parser) keyword(word string) bool {
+	tok := p.peek()
+	return tok.kind == tWord && strings.EqualFold(tok.text, word)
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return &SyntaxError{p.expr, tok.pos, fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) parse() (node, error) {
+	if p.peek().kind == tEOF {
+		return nil, p.errorf(p.peek(), "empty filter")
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tEOF {
+		if tok.kind == tRParen {
+			return nil, p.errorf(tok, "unbalanced parenthesis")
+		}
+		return nil, p.errorf(tok, "expected and, or or the end of the filter, got %s", tok.describe())
+	}
+	return root, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("or") {
+		p.advance()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("and") {
+		p.advance()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.keyword("not") {
+		p.advance()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+
+	if p.peek().kind == tLParen {
+		open := p.advance()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tRParen {
+			return nil, p.errorf(open, "missing closing parenthesis")
+		}
+		p.advance()
+		return inner, nil
+	}
+
+	return p.parsePredicate()
+}
+
+// textOperators maps the operators of the text fields to their canonical form
+var textOperators = map[string]string{
+	"=": "=", "==": "=", "!=": "!=", "contains": "contains",
+	"~": "~", "matches": "~", "!~": "!~",
+}
+
+// numberOperators maps the operators of score and age to their canonical form
+var numberOperators = map[string]string{
+	"=": "=", "==": "=", "!=": "!=", "<": "<", "<=": "<=", ">": ">", ">=": ">=",
+}
+
+// operator reads the operator of a predicate on `field`
+func (p *parser) operator(field string, valid map[string]string) (string, error) {
+	tok := p.advance()
+	if tok.kind == tOperator || tok.kind == tWord {
+		if op, ok := valid[strings.ToLower(tok.text)]; ok {
+			return op, nil
+		}
+	}
+
+	names := make([]string, 0, len(valid))
+	for _, op := range append(operators, "contains", "matches") {
+		if _, ok := valid[op]; ok {
+			names = append(names, op)
+		}
+	}
+	return "", p.errorf(tok, "expected an operator after %s (%s), got %s", field, strings.Join(names, " "), tok.describe())
+}
+
+// value reads the value of a predicate
+func (p *parser) value() (token, error) {
+	tok := p.advance()
+	if tok.kind != tWord && tok.kind != tString && tok.kind != tRegex {
+		return tok, p.errorf(tok, "expected a value, got %s", tok.describe())
+	}
+	return tok, nil
+}
+
+func (p *parser) parsePredicate() (node, error) {
+	tok := p.advance()
+	if tok.kind != tWord {
+		return nil, p.errorf(tok, "expected a predicate, got %s", tok.describe())
+	}
+
+	field := strings.ToLower(tok.text)
+	switch field {
+	case "giveaway", "giveaways":
+		return giveawayNode{}, nil
+
+	case "score":
+		op, err := p.operator(field, numberOperators)
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		score, err := strconv.ParseInt(value.text, 10, 64)
+		if err != nil || value.kind != tWord {
+			return nil, p.errorf(value, "score must be compared to an integer")
+		}
+		return scoreNode{op, score}, nil
+
+	case "age":
+		op, err := p.operator(field, numberOperators)
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		age, err := duration.Parse(value.text)
+		if err != nil || value.kind != tWord {
+			return nil, p.errorf(value, "age must be compared to a duration (e.g. 30m, 6h, 2d)")
+		}
+		return ageNode{op, age}, nil
+	}
+
+	getter, ok := textFields[field]
+	if !ok {
+		return nil, p.errorf(tok, "unknown predicate %q, expected giveaway, title, selftext, flair, author, score or age", tok.text)
+	}
+
+	op, err := p.operator(field, textOperators)
+	if err != nil {
+		return nil, err
+	}
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+
+	n := textNode{field: getter, op: op, value: value.text}
+	if op == "~" || op == "!~" {
+		n.rx, err = regexp.Compile(value.text)
+		if err != nil {
+			return nil, p.errorf(value, "invalid regular expression: %v", err)
+		}
+	} else if value.kind == tRegex {
+		return nil, p.errorf(value, "a regular expression requires ~ or matches")
+	}
+	return n, nil
+}
@@ -0,0 +1,112 @@
+package giveaway
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// MinExamples is the number of examples of each class required before a
+// NaiveBayes model is used by a Classifier
+const MinExamples = 5
+
+// Example is a post labelled by a user
+type Example struct {
+	Title    string `json:"title"`
+	Flair    string `json:"flair,omitempty"`
+	Body     string `json:"body,omitempty"`
+	Giveaway bool   `json:"giveaway"`
+}
+
+// NaiveBayes is a naive Bayes model of the words of the giveaways and of the
+// other posts, trained from labelled examples.
+type NaiveBayes struct {
+	// counts[1] counts the giveaways containing each token, counts[0] the
+	// other posts
+	counts [2]map[string]int
+	// tokens[c] is the total number of tokens in the examples of class c
+	tokens [2]int
+	// examples[c] is the number of examples of class c
+	examples   [2]int
+	vocabulary map[string]bool
+}
+
+var tokenRx = regexp.MustCompile(`[a-z0-9]+`)
+
+// tokenize returns the distinct words of a post, the words of the title and
+// flair being told apart from the ones of the body
+func tokenize(title, flair, body string) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+
+	add := func(prefix, text string) {
+		for _, word := range tokenRx.FindAllString(strings.ToLower(text), -1) {
+			token := prefix + word
+			if !seen[token] {
+				seen[token] = true
+				tokens = append(tokens, token)
+			}
+		}
+	}
+
+	add("t:", title)
+	add("f:", flair)
+	add("", body)
+
+	return tokens
+}
+
+// class returns the index of the class of a label
+func class(giveaway bool) int {
+	if giveaway {
+		return 1
+	}
+	return 0
+}
+
+// TrainNaiveBayes returns a model trained on `examples`
+func TrainNaiveBayes(examples []Example) *NaiveBayes {
+	model := &NaiveBayes{
+		counts:     [2]map[string]int{{}, {}},
+		vocabulary: make(map[string]bool),
+	}
+
+	for _, example := range examples {
+		c := class(example.Giveaway)
+		model.examples[c]++
+		for _, token := range tokenize(example.Title, example.Flair, example.Body) {
+			model.counts[c][token]++
+			model.tokens[c]++
+			model.vocabulary[token] = true
+		}
+	}
+
+	return model
+}
+
+// Trained returns true if the model has seen enough examples of both classes
+// to be meaningful
+func (m *NaiveBayes) Trained() bool {
+	return m.examples[0] >= MinExamples && m.examples[1] >= MinExamples
+}
+
+// Probability returns the probability that a post is a giveaway
+func (m *NaiveBayes) Probability(title, flair, body string) float64 {
+	total := float64(m.examples[0] + m.examples[1])
+	vocabulary := float64(len(m.vocabulary))
+
+	var logs [2]float64
+	for c := range logs {
+		// Laplace smoothing of the prior and of the likelihoods
+		logs[c] = math.Log((float64(m.examples[c]) + 1) / (total + 2))
+		for _, token := range tokenize(title, flair, body) {
+			if !m.vocabulary[token] {
+				continue
+			}
+			logs[c] += math.Log((float64(m.counts[c][token]) + 1) / (float64(m.tokens[c]) + vocabulary))
+		}
+	}
+
+	// 1 / (1 + P(other)/P(giveaway)) computed from the log probabilities
+	return 1 / (1 + math.Exp(logs[0]-logs[1]))
+}
@@ -0,0 +1,57 @@
+package giveaway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// labelled are examples of posts a user labelled
+var labelled = []Example{
+	{Title: "Giveaway: GMK Olivia", Giveaway: true},
+	{Title: "Giveaway time, artisan keycap", Giveaway: true},
+	{Title: "Celebrating 10k, giveaway", Giveaway: true},
+	{Title: "Deskmat giveaway, comment to enter", Giveaway: true},
+	{Title: "Giveaway for a lubed switch set", Giveaway: true},
+	{Title: "Giveaway: the winners of the contest", Giveaway: false},
+	{Title: "Winners from the vendor giveaway", Giveaway: false},
+	{Title: "Winners of the giveaway, thanks all", Giveaway: false},
+	{Title: "Contest winners, giveaway", Giveaway: false},
+	{Title: "Giveaway contest winners list", Giveaway: false},
+}
+
+func TestNaiveBayes(t *testing.T) {
+	model := TrainNaiveBayes(labelled)
+	assert.True(t, model.Trained())
+	assert.False(t, TrainNaiveBayes(labelled[:4]).Trained())
+
+	assert.Greater(t, model.Probability("Keycap giveaway, comment to enter", "", ""), 0.5)
+	assert.Less(t, model.Probability("Giveaway winners", "", ""), 0.5)
+}
+
+func TestClassifierWithModel(t *testing.T) {
+	rules := DefaultClassifier()
+	mixed := rules.WithModel(TrainNaiveBayes(labelled))
+
+	// the rules alone can't tell these winner announcements apart
+	assert.True(t, rules.Classify("Contest winners of the giveaway", "", "").Giveaway)
+
+	result := mixed.Classify("Contest winners of the giveaway", "", "")
+	assert.False(t, result.Giveaway, result.String())
+	assert.Equal(t, modelRule, result.Matches[len(result.Matches)-1].Rule)
+
+	// an untrained model is ignored
+	untrained := rules.WithModel(TrainNaiveBayes(labelled[:4]))
+	assert.Len(t, untrained.Classify("Contest winners of the giveaway", "", "").Matches, 1)
+}
+
+func TestEvaluate(t *testing.T) {
+	report := Evaluate(DefaultClassifier(), labelled)
+	assert.Equal(t, Report{TruePositives: 5, FalsePositives: 5}, report)
+	assert.Equal(t, 0.5, report.Precision())
+	assert.Equal(t, 1.0, report.Recall())
+
+	// every example is used for testing exactly once
+	crossed := CrossValidate(DefaultClassifier(), labelled, 5)
+	assert.Equal(t, len(labelled), crossed.TruePositives+crossed.FalsePositives+crossed.TrueNegatives+crossed.FalseNegatives)
+}
@@ -0,0 +1,245 @@
+package giveaway
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Field is a part of a post a Rule applies to
+type Field string
+
+const (
+	Title Field = "title"
+	Flair Field = "flair"
+	Body  Field = "body"
+)
+
+// Rule adds Weight to the score of a post if Pattern matches any of its
+// Fields. Negation phrases ("not a giveaway", "giveaway ended") are rules
+// with a negative weight.
+type Rule struct {
+	Name string `json:"name"`
+	// Pattern is a regular expression (RE2 syntax), use the (?i) flag for a
+	// case insensitive match
+	Pattern string `json:"pattern"`
+	// Fields defaults to the title only
+	Fields []Field `json:"fields,omitempty"`
+	Weight float64 `json:"weight"`
+
+	rx *regexp.Regexp
+}
+
+// Classifier tells giveaways apart by summing the weight of the rules
+// matching a post: posts scoring at least Threshold are giveaways. When a
+// trained model is attached (see WithModel), it adds up to ModelWeight to the
+// score of the posts it considers as giveaways and removes up to ModelWeight
+// from the others.
+type Classifier struct {
+	Threshold float64 `json:"threshold"`
+	Rules     []*Rule `json:"rules"`
+	// ModelWeight defaults to DefaultModelWeight, a negative value disables
+	// the model
+	ModelWeight float64 `json:"model_weight,omitempty"`
+
+	model *NaiveBayes
+}
+
+// DefaultModelWeight is the default weight of the model of a Classifier
+const DefaultModelWeight = 3
+
+// modelRule is the name of the contribution of the model in a Classification
+const modelRule = "feedback model"
+
+// DefaultRules are the rules of DefaultClassifier. A giveaway mentioned only
+// in the body reaches the threshold with its entry rules, not alone.
+var DefaultRules = []*Rule{
+	{Name: "giveaway", Pattern: `(?i)\bgive ?aways?\b`, Weight: 3},
+	{Name: "GA", Pattern: `\bGA\b`, Weight: 3},
+	{Name: "raffle", Pattern: `(?i)\braffles?\b`, Weight: 3},
+	{Name: "free stuff", Pattern: `(?i)\bfree\s+(keycaps?|keyboards?|switch(es)?|desk ?mats?|artisans?|cables?|stuff)\b`, Weight: 3},
+	{Name: "giveaway flair", Pattern: `(?i)give ?away|raffle`, Fields: []Field{Flair}, Weight: 3},
+	{Name: "giveaway mention", Pattern: `(?i)\b(give ?away|raffle)\b`, Fields: []Field{Body}, Weight: 2},
+	{Name: "entry rules", Pattern: `(?i)\b(to enter|comment below|leave a comment|winners? will be|randomly (chosen|selected|picked|drawn))\b`, Fields: []Field{Body}, Weight: 1},
+	{Name: "ended", Pattern: `(?i)\b(give ?away|GA|raffle)\s*(is\s+|has\s+)?(now\s+)?(ended|over|closed|finished)\b|[\[(](ended|closed|over|finished)[\])]`, Fields: []Field{Title, Flair}, Weight: -6},
+	{Name: "results", Pattern: `(?i)\b(winners?\s+(announced|selected|chosen|drawn)|(give ?away|raffle)\s+(results|winners?))\b`, Fields: []Field{Title, Flair}, Weight: -6},
+	{Name: "not a giveaway", Pattern: `(?i)\bnot\s+an?\s+(give ?away|GA|raffle)\b`, Fields: []Field{Title, Body}, Weight: -6},
+}
+
+// DefaultThreshold is the threshold of DefaultClassifier
+const DefaultThreshold = 3
+
+// NewClassifier compiles the rules and returns the classifier
+func NewClassifier(threshold float64, rules []*Rule) (*Classifier, error) {
+	classifier := &Classifier{Threshold: threshold, Rules: rules}
+	err := classifier.compile()
+	if err != nil {
+		return nil, err
+	}
+	return classifier, nil
+}
+
+// DefaultClassifier returns a classifier using DefaultRules
+func DefaultClassifier() *Classifier {
+	classifier, err := NewClassifier(DefaultThreshold, DefaultRules)
+	if err != nil {
+		panic(err)
+	}
+	return classifier
+}
+
+// LoadClassifier reads a classifier from a JSON file, e.g.
+// {"threshold": 3, "rules": [{"name": "giveaway", "pattern": "(?i)giveaway", "weight": 3}]}
+func LoadClassifier(path string) (*Classifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	classifier := &Classifier{}
+	err = json.Unmarshal(data, classifier)
+	if err != nil {
+		return nil, err
+	}
+
+	err = classifier.compile()
+	if err != nil {
+		return nil, err
+	}
+	return classifier, nil
+}
+
+// compile checks and compiles the rules. The classifier gets its own copy of
+// the rules, which may be shared (e.g. DefaultRules).
+func (c *Classifier) compile() error {
+	if len(c.Rules) == 0 {
+		return fmt.Errorf("classifier without any rule")
+	}
+
+	rules := make([]*Rule, len(c.Rules))
+	for i, shared := range c.Rules {
+		rule := *shared
+		if len(rule.Name) == 0 {
+			return fmt.Errorf("rule %d has no name", i)
+		}
+		for _, field := range rule.Fields {
+			if field != Title && field != Flair && field != Body {
+				return fmt.Errorf("rule %q: unknown field %q", rule.Name, field)
+			}
+		}
+
+		rx, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("rule %q: %v", rule.Name, err)
+		}
+		rule.rx = rx
+		rules[i] = &rule
+	}
+	c.Rules = rules
+	return nil
+}
+
+// WithModel returns a copy of the classifier mixing `model` into the score.
+// The model is ignored until it is trained on enough examples.
+func (c *Classifier) WithModel(model *NaiveBayes) *Classifier {
+	mixed := *c
+	mixed.model = model
+	return &mixed
+}
+
+// modelWeight returns the weight of the model, zero if there is no usable
+// model
+func (c *Classifier) modelWeight() float64 {
+	if c.model == nil || !c.model.Trained() || c.ModelWeight < 0 {
+		return 0
+	}
+	if c.ModelWeight == 0 {
+		return DefaultModelWeight
+	}
+	return c.ModelWeight
+}
+
+// Match is a rule matching a post
+type Match struct {
+	Rule   string
+	Field  Field
+	Weight float64
+	// Text is the matched text
+	Text string
+}
+
+// String explains the match, e.g. `giveaway: "Giveaway" in title (+3)`
+func (m Match) String() string {
+	if len(m.Field) == 0 {
+		return fmt.Sprintf("%s: %s (%+.2g)", m.Rule, m.Text, m.Weight)
+	}
+	return fmt.Sprintf("%s: %q in %s (%+g)", m.Rule, m.Text, m.Field, m.Weight)
+}
+
+// Classification is the result of a Classifier on a post
+type Classification struct {
+	Score    float64
+	Giveaway bool
+	// Matches are the rules which contributed to the score
+	Matches []Match
+}
+
+// Classify scores a post from its title, link flair and selftext. Each rule
+// counts once, on the first of its fields it matches.
+func (c *Classifier) Classify(title, flair, body string) Classification {
+	fields := map[Field]string{Title: title, Flair: flair, Body: body}
+
+	var result Classification
+	for _, rule := range c.Rules {
+		ruleFields := rule.Fields
+		if len(ruleFields) == 0 {
+			ruleFields = []Field{Title}
+		}
+
+		for _, field := range ruleFields {
+			text := rule.rx.FindString(fields[field])
+			if len(text) == 0 {
+				continue
+			}
+
+			result.Score += rule.Weight
+			result.Matches = append(result.Matches, Match{
+				Rule:   rule.Name,
+				Field:  field,
+				Weight: rule.Weight,
+				Text:   text,
+			})
+			break
+		}
+	}
+
+	if weight := c.modelWeight(); weight > 0 {
+		p := c.model.Probability(title, flair, body)
+		contribution := weight * (2*p - 1)
+
+		result.Score += contribution
+		result.Matches = append(result.Matches, Match{
+			Rule:   modelRule,
+			Weight: contribution,
+			Text:   fmt.Sprintf("%.0f%% giveaway", 100*p),
+		})
+	}
+
+	result.Giveaway = len(result.Matches) > 0 && result.Score >= c.Threshold
+	return result
+}
+
+// String explains the classification, e.g. `giveaway: "Giveaway" in title (+3)`
+func (r Classification) String() string {
+	if len(r.Matches) == 0 {
+		return "no rule matched"
+	}
+
+	reasons := make([]string, len(r.Matches))
+	for i, match := range r.Matches {
+		reasons[i] = match.String()
+	}
+	return fmt.Sprintf("score %.3g, %s", r.Score, strings.Join(reasons, ", "))
+}
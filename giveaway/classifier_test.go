@@ -0,0 +1,75 @@
+package giveaway
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultClassifier(t *testing.T) {
+	classifier := DefaultClassifier()
+
+	cases := []struct {
+		title, flair, body string
+		giveaway           bool
+	}{
+		{"GMK Olivia keycaps [Giveaway]", "", "", true},
+		{"Deskmat GA, worldwide", "", "", true},
+		{"Raffle for a custom board", "", "", true},
+		{"Free keycaps to a good home", "", "", true},
+		{"My new build", "Giveaway", "", true},
+		{"[Giveaway ended] GMK Olivia", "", "", false},
+		{"Keycaps giveaway results", "", "", false},
+		{"This is not a giveaway but look at my board", "", "", false},
+		{"Thoughts on the gateron ink switches?", "", "", false},
+		{"Saga of my first build", "", "", false},
+		{"Keycaps", "Photos", "Giveaway time! To enter, comment below.", true},
+		{"Time for a gift", "Photos", "This is a giveaway, comment below to enter", true},
+		{"Keycaps", "Photos", "I won this set in a giveaway last year", false},
+	}
+
+	for _, c := range cases {
+		result := classifier.Classify(c.title, c.flair, c.body)
+		assert.Equal(t, c.giveaway, result.Giveaway, "%s: %s", c.title, result)
+	}
+}
+
+func TestDefaultRulesUnchanged(t *testing.T) {
+	DefaultClassifier()
+	for _, rule := range DefaultRules {
+		assert.Nil(t, rule.rx, rule.Name)
+	}
+}
+
+func TestClassificationString(t *testing.T) {
+	result := DefaultClassifier().Classify("GMK Giveaway", "", "")
+	assert.Equal(t, `score 3, giveaway: "Giveaway" in title (+3)`, result.String())
+	assert.Equal(t, "no rule matched", DefaultClassifier().Classify("Hi", "", "").String())
+}
+
+func TestLoadClassifier(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "rules.json")
+	err := os.WriteFile(path, []byte(`{
+		"threshold": 2,
+		"rules": [
+			{"name": "group buy", "pattern": "(?i)\\bgb\\b", "weight": 2},
+			{"name": "body", "pattern": "(?i)group buy", "fields": ["body", "flair"], "weight": 1}
+		]
+	}`), 0644)
+	assert.Nil(t, err)
+
+	classifier, err := LoadClassifier(path)
+	assert.Nil(t, err)
+	assert.True(t, classifier.Classify("[GB] GMK Olivia", "", "").Giveaway)
+	assert.False(t, classifier.Classify("GMK Olivia", "Group buy", "").Giveaway)
+
+	invalid := filepath.Join(dir, "invalid.json")
+	err = os.WriteFile(invalid, []byte(`{"rules": [{"name": "x", "pattern": "(", "weight": 1}]}`), 0644)
+	assert.Nil(t, err)
+	_, err = LoadClassifier(invalid)
+	assert.NotNil(t, err)
+}
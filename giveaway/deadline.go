@@ -0,0 +1,301 @@
+// giveaway extracts structured information from the giveaway posts, such as
+// their deadline and the regions they are open to.
+package giveaway
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxDeadline is the largest delay between a post and its deadline, later
+// dates are most likely not deadlines (e.g. the shipping date of a group buy)
+const maxDeadline = 90 * 24 * time.Hour
+
+// DefaultLocation is the time zone of the deadlines without any time zone
+var DefaultLocation = time.UTC
+
+// zones maps the explicit standard and daylight time abbreviations often
+// used on reddit to their offset in minutes
+var zones = map[string]int{
+	"utc": 0, "gmt": 0,
+	"est": -5 * 60, "edt": -4 * 60,
+	"cst": -6 * 60, "cdt": -5 * 60,
+	"mst": -7 * 60, "mdt": -6 * 60,
+	"pst": -8 * 60, "pdt": -7 * 60,
+	"bst": 1 * 60, "cet": 1 * 60, "cest": 2 * 60, "eet": 2 * 60, "eest": 3 * 60,
+	"ist": 5*60 + 30, "sgt": 8 * 60, "jst": 9 * 60, "kst": 9 * 60,
+	"aest": 10 * 60, "aedt": 11 * 60,
+}
+
+// genericZone is a time zone abbreviation which doesn't tell standard from
+// daylight time (e.g. "ET"): its offset depends on the date
+type genericZone struct {
+	location string
+	// standard is the offset of the standard time in minutes, used when the
+	// location isn't available or an offset is added (e.g. "ET+1")
+	standard int
+}
+
+var genericZones = map[string]genericZone{
+	"et":  {"America/New_York", -5 * 60},
+	"ct":  {"America/Chicago", -6 * 60},
+	"mt":  {"America/Denver", -7 * 60},
+	"pt":  {"America/Los_Angeles", -8 * 60},
+	"aet": {"Australia/Sydney", 10 * 60},
+}
+
+var months = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March,
+	"apr": time.April, "may": time.May, "jun": time.June,
+	"jul": time.July, "aug": time.August, "sep": time.September,
+	"oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday,
+	"wed": time.Wednesday, "thu": time.Thursday, "fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+var units = map[string]time.Duration{
+	"min": time.Minute, "minute": time.Minute,
+	"hr": time.Hour, "hour": time.Hour,
+	"day": 24 * time.Hour, "week": 7 * 24 * time.Hour,
+}
+
+var numbers = map[string]int{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+	"six": 6, "seven": 7, "ten": 10, "twelve": 12,
+}
+
+const monthPattern = `(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?`
+
+var (
+	// a word announcing a deadline, the deadline is looked for in the text
+	// following it
+	triggerRx = regexp.MustCompile(`\b(ends?|ending|closes?|closing|closed on|until|till|deadline|draws?|drawing|winners? (?:will be )?(?:picked|chosen|drawn|announced)|open through)\b`)
+
+	relativeRx = regexp.MustCompile(`\bin (\d+|an?|one|two|three|four|five|six|seven|ten|twelve) ?(min|minute|hr|hour|day|week)s?\b`)
+	isoRx      = regexp.MustCompile(`\b(\d{4})-(\d{1,2})-(\d{1,2})\b`)
+	slashRx    = regexp.MustCompile(`\b(\d{1,2})/(\d{1,2})(?:/(\d{2}|\d{4}))?\b`)
+	monthDayRx = regexp.MustCompile(`\b` + monthPattern + `\s+(\d{1,2})(?:st|nd|rd|th)?\b(?:,?\s+(\d{4})\b)?`)
+	dayMonthRx = regexp.MustCompile(`\b(\d{1,2})(?:st|nd|rd|th)?\s+(?:of\s+)?` + monthPattern + `(?:,?\s+(\d{4})\b)?`)
+	weekdayRx  = regexp.MustCompile(`\b(sun|mon|tue|wed|thu|fri|sat)(?:day|s|sday|nesday|rsday|urday|\.)?\b`)
+	dayWordRx  = regexp.MustCompile(`\b(today|tonight|tomorrow)\b`)
+	clockRx    = regexp.MustCompile(`\b(\d{1,2})(?::(\d{2}))?\s*(am|pm|a\.m\.|p\.m\.)`)
+	hourRx     = regexp.MustCompile(`\b(\d{1,2}):(\d{2})\b`)
+	noonRx     = regexp.MustCompile(`\b(noon|midnight)\b`)
+	zoneRx     = regexp.MustCompile(`\b(utc|gmt|[a-z]{2,4})\s*([+-]\d{1,2})?(?::?(\d{2}))?\b`)
+)
+
+// ParseDeadline looks for the end date of a giveaway in `text` (e.g. the
+// title and selftext of a post) published at `posted`. Relative dates
+// ("tomorrow", "Friday", "in 3 days") are relative to `posted`. The boolean
+// is false if no deadline was found.
+func ParseDeadline(text string, posted time.Time) (time.Time, bool) {
+	text = strings.ToLower(text)
+
+	for _, loc := range triggerRx.FindAllStringIndex(text, -1) {
+		segment := text[loc[1]:]
+		if end := strings.IndexAny(segment, "\n!?;"); end >= 0 {
+			segment = segment[:end]
+		}
+		if len(segment) > 80 {
+			segment = segment[:80]
+		}
+
+		deadline, ok := parseSegment(segment, posted)
+		if ok && deadline.After(posted) && deadline.Sub(posted) < maxDeadline {
+			return deadline, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// parseSegment parses the text following a trigger word
+func parseSegment(segment string, posted time.Time) (time.Time, bool) {
+	if match := relativeRx.FindStringSubmatch(segment); match != nil {
+		count, ok := numbers[match[1]]
+		if !ok {
+			count, _ = strconv.Atoi(match[1])
+		}
+		return posted.Add(time.Duration(count) * units[match[2]]), true
+	}
+
+	ref := posted.In(parseZone(segment))
+
+	hour, minute, hasClock := parseClock(segment)
+	year, month, day, hasDate := parseDate(segment, ref)
+
+	if !hasDate {
+		if weekday, ok := parseWeekday(segment); ok {
+			ahead := (int(weekday) - int(ref.Weekday()) + 7) % 7
+			year, month, day = ref.AddDate(0, 0, ahead).Date()
+			hasDate = true
+
+			// "ends friday" on a friday is most likely next week
+			if ahead == 0 && !(hasClock && later(ref, hour, minute)) {
+				year, month, day = ref.AddDate(0, 0, 7).Date()
+			}
+		}
+	}
+
+	if !hasDate {
+		if match := dayWordRx.FindStringSubmatch(segment); match != nil {
+			offset := 0
+			if match[1] == "tomorrow" {
+				offset = 1
+			}
+			year, month, day = ref.AddDate(0, 0, offset).Date()
+			hasDate = true
+		}
+	}
+
+	switch {
+	case hasDate && !hasClock:
+		// end of the day
+		hour, minute = 23, 59
+	case !hasDate && hasClock:
+		// next occurrence of the time
+		offset := 0
+		if !later(ref, hour, minute) {
+			offset = 1
+		}
+		year, month, day = ref.AddDate(0, 0, offset).Date()
+	case !hasDate && !hasClock:
+		return time.Time{}, false
+	}
+
+	return time.Date(year, month, day, hour, minute, 0, 0, ref.Location()), true
+}
+
+// later returns true if hour:minute is later than ref on the same day
+func later(ref time.Time, hour, minute int) bool {
+	return hour > ref.Hour() || (hour == ref.Hour() && minute > ref.Minute())
+}
+
+// parseZone returns the first known time zone of the segment
+func parseZone(segment string) *time.Location {
+	for _, match := range zoneRx.FindAllStringSubmatch(segment, -1) {
+		offset, ok := zones[match[1]]
+		if generic, isGeneric := genericZones[match[1]]; isGeneric {
+			if location, err := time.LoadLocation(generic.location); err == nil && match[2] == "" {
+				return location
+			}
+			offset, ok = generic.standard, true
+		}
+		if !ok {
+			continue
+		}
+
+		name := strings.ToUpper(match[1])
+		if match[2] != "" {
+			hours, _ := strconv.Atoi(match[2])
+			minutes, _ := strconv.Atoi(match[3])
+			if hours < 0 {
+				minutes = -minutes
+			}
+			offset += hours*60 + minutes
+			name += match[2]
+		}
+
+		return time.FixedZone(name, offset*60)
+	}
+
+	return DefaultLocation
+}
+
+// parseClock returns the first time of the day of the segment
+func parseClock(segment string) (int, int, bool) {
+	if match := clockRx.FindStringSubmatch(segment); match != nil {
+		hour, _ := strconv.Atoi(match[1])
+		minute, _ := strconv.Atoi(match[2])
+		if hour < 1 || hour > 12 || minute > 59 {
+			return 0, 0, false
+		}
+
+		hour %= 12
+		if strings.HasPrefix(match[3], "p") {
+			hour += 12
+		}
+		return hour, minute, true
+	}
+
+	if match := hourRx.FindStringSubmatch(segment); match != nil {
+		hour, _ := strconv.Atoi(match[1])
+		minute, _ := strconv.Atoi(match[2])
+		if hour > 23 || minute > 59 {
+			return 0, 0, false
+		}
+		return hour, minute, true
+	}
+
+	if match := noonRx.FindStringSubmatch(segment); match != nil {
+		if match[1] == "noon" {
+			return 12, 0, true
+		}
+		return 23, 59, true
+	}
+
+	return 0, 0, false
+}
+
+// parseDate returns the first calendar date of the segment, the year is
+// guessed from `ref` if missing
+func parseDate(segment string, ref time.Time) (int, time.Month, int, bool) {
+	var year, monthNumber, day int
+
+	if match := isoRx.FindStringSubmatch(segment); match != nil {
+		year, _ = strconv.Atoi(match[1])
+		monthNumber, _ = strconv.Atoi(match[2])
+		day, _ = strconv.Atoi(match[3])
+	} else if match := monthDayRx.FindStringSubmatch(segment); match != nil {
+		monthNumber = int(months[match[1]])
+		day, _ = strconv.Atoi(match[2])
+		year, _ = strconv.Atoi(match[3])
+	} else if match := dayMonthRx.FindStringSubmatch(segment); match != nil {
+		day, _ = strconv.Atoi(match[1])
+		monthNumber = int(months[match[2]])
+		year, _ = strconv.Atoi(match[3])
+	} else if match := slashRx.FindStringSubmatch(segment); match != nil {
+		// month first unless it can't be
+		monthNumber, _ = strconv.Atoi(match[1])
+		day, _ = strconv.Atoi(match[2])
+		if monthNumber > 12 {
+			monthNumber, day = day, monthNumber
+		}
+		year, _ = strconv.Atoi(match[3])
+		if year > 0 && year < 100 {
+			year += 2000
+		}
+	} else {
+		return 0, 0, 0, false
+	}
+
+	if monthNumber < 1 || monthNumber > 12 || day < 1 || day > 31 {
+		return 0, 0, 0, false
+	}
+	month := time.Month(monthNumber)
+
+	if year == 0 {
+		year = ref.Year()
+		// a date in the past is next year's
+		if time.Date(year, month, day, 23, 59, 0, 0, ref.Location()).Before(ref) {
+			year++
+		}
+	}
+
+	return year, month, day, true
+}
+
+// parseWeekday returns the first day of the week of the segment
+func parseWeekday(segment string) (time.Weekday, bool) {
+	match := weekdayRx.FindStringSubmatch(segment)
+	if match == nil {
+		return 0, false
+	}
+	return weekdays[match[1]], true
+}
@@ -0,0 +1,85 @@
+package giveaway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Wednesday 2021-12-15 14:00 UTC
+var posted = time.Date(2021, time.December, 15, 14, 0, 0, 0, time.UTC)
+
+func TestParseDeadline(t *testing.T) {
+	est := time.FixedZone("EST", -5*3600)
+	pst := time.FixedZone("PST", -8*3600)
+
+	cases := map[string]time.Time{
+		"[Giveaway] GMK keycaps, ends Friday 5pm EST":          time.Date(2021, time.December, 17, 17, 0, 0, 0, est),
+		"Giveaway! Closes 12/24":                               time.Date(2021, time.December, 24, 23, 59, 0, 0, time.UTC),
+		"giveaway ending on December 20th at 11:59 PM PST":     time.Date(2021, time.December, 20, 23, 59, 0, 0, pst),
+		"The giveaway will end on 20 dec":                      time.Date(2021, time.December, 20, 23, 59, 0, 0, time.UTC),
+		"Ends in 3 days":                                       posted.Add(72 * time.Hour),
+		"ends in an hour":                                      posted.Add(time.Hour),
+		"Winners will be picked tomorrow at noon":              time.Date(2021, time.December, 16, 12, 0, 0, 0, time.UTC),
+		"giveaway ends tonight":                                time.Date(2021, time.December, 15, 23, 59, 0, 0, time.UTC),
+		"Deadline: 2022-01-03 18:00 UTC":                       time.Date(2022, time.January, 3, 18, 0, 0, 0, time.UTC),
+		"Ends Wednesday":                                       time.Date(2021, time.December, 22, 23, 59, 0, 0, time.UTC),
+		"Open until 3pm":                                       time.Date(2021, time.December, 15, 15, 0, 0, 0, time.UTC),
+		"Open until 9am":                                       time.Date(2021, time.December, 16, 9, 0, 0, 0, time.UTC),
+		"I'll draw... ok it ends jan 2nd, 2022 (UTC+1)":        time.Date(2022, time.January, 2, 23, 59, 0, 0, time.FixedZone("UTC+1", 3600)),
+		"Keyboard giveaway\nrules: comment below\nends 24/12.": time.Date(2021, time.December, 24, 23, 59, 0, 0, time.UTC),
+	}
+
+	for text, expected := range cases {
+		deadline, ok := ParseDeadline(text, posted)
+		assert.True(t, ok, text)
+		assert.True(t, expected.Equal(deadline), "%s: expected %v got %v", text, expected, deadline)
+	}
+}
+
+func TestParseDeadlineGenericZone(t *testing.T) {
+	if _, err := time.LoadLocation("America/New_York"); err != nil {
+		t.Skip("no time zone database")
+	}
+	summer := time.Date(2022, time.July, 1, 14, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		text     string
+		posted   time.Time
+		expected time.Time
+	}{
+		// "ET" and "PT" follow daylight saving time
+		{"ends July 4 5pm ET", summer, time.Date(2022, time.July, 4, 21, 0, 0, 0, time.UTC)},
+		{"ends July 4 5pm PT", summer, time.Date(2022, time.July, 5, 0, 0, 0, 0, time.UTC)},
+		{"ends December 20 5pm ET", posted, time.Date(2021, time.December, 20, 22, 0, 0, 0, time.UTC)},
+		// the explicit forms don't
+		{"ends July 4 5pm EST", summer, time.Date(2022, time.July, 4, 22, 0, 0, 0, time.UTC)},
+		{"ends July 4 5pm EDT", summer, time.Date(2022, time.July, 4, 21, 0, 0, 0, time.UTC)},
+		{"ends July 4 5pm PDT", summer, time.Date(2022, time.July, 5, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		deadline, ok := ParseDeadline(c.text, c.posted)
+		assert.True(t, ok, c.text)
+		assert.True(t, c.expected.Equal(deadline), "%s: expected %v got %v", c.text, c.expected, deadline)
+	}
+
+	deadline, _ := ParseDeadline("ends July 4 5pm ET", summer)
+	assert.Equal(t, "America/New_York", deadline.Location().String())
+	deadline, _ = ParseDeadline("ends December 20 5pm PT", posted)
+	assert.Equal(t, "America/Los_Angeles", deadline.Location().String())
+}
+
+func TestParseDeadlineNotFound(t *testing.T) {
+	for _, text := range []string{
+		"[Giveaway] GMK keycaps",
+		"My endgame board",
+		"Giveaway ended, thanks all",
+		"Ends 12/01", // in the past : would be next year, too far
+		"The weekend build",
+	} {
+		_, ok := ParseDeadline(text, posted)
+		assert.False(t, ok, text)
+	}
+}
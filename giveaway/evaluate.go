@@ -0,0 +1,91 @@
+package giveaway
+
+import "fmt"
+
+// Report counts the decisions of a classifier on labelled examples
+type Report struct {
+	TruePositives  int
+	FalsePositives int
+	TrueNegatives  int
+	FalseNegatives int
+}
+
+// add counts a decision
+func (r *Report) add(predicted, actual bool) {
+	switch {
+	case predicted && actual:
+		r.TruePositives++
+	case predicted && !actual:
+		r.FalsePositives++
+	case !predicted && actual:
+		r.FalseNegatives++
+	default:
+		r.TrueNegatives++
+	}
+}
+
+// Precision is the fraction of the posts classified as giveaways which are
+// giveaways (1 if no post was classified as a giveaway)
+func (r Report) Precision() float64 {
+	if r.TruePositives+r.FalsePositives == 0 {
+		return 1
+	}
+	return float64(r.TruePositives) / float64(r.TruePositives+r.FalsePositives)
+}
+
+// Recall is the fraction of the giveaways classified as giveaways (1 if there
+// are no giveaways)
+func (r Report) Recall() float64 {
+	if r.TruePositives+r.FalseNegatives == 0 {
+		return 1
+	}
+	return float64(r.TruePositives) / float64(r.TruePositives+r.FalseNegatives)
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf(
+		"precision %.1f%%, recall %.1f%% (%d true positives, %d false positives, %d false negatives, %d true negatives)",
+		100*r.Precision(), 100*r.Recall(),
+		r.TruePositives, r.FalsePositives, r.FalseNegatives, r.TrueNegatives,
+	)
+}
+
+// Evaluate classifies the examples with `classifier` and compares the
+// decisions to their labels
+func Evaluate(classifier *Classifier, examples []Example) Report {
+	var report Report
+	for _, example := range examples {
+		result := classifier.Classify(example.Title, example.Flair, example.Body)
+		report.add(result.Giveaway, example.Giveaway)
+	}
+	return report
+}
+
+// CrossValidate evaluates `classifier` mixed with a naive Bayes model by
+// k-fold cross validation: each example is classified by a model trained on
+// the other folds, so the model is never evaluated on its own training data.
+func CrossValidate(classifier *Classifier, examples []Example, folds int) Report {
+	var report Report
+	if folds < 2 {
+		folds = 2
+	}
+
+	for fold := 0; fold < folds; fold++ {
+		var training, testing []Example
+		for i, example := range examples {
+			if i%folds == fold {
+				testing = append(testing, example)
+			} else {
+				training = append(training, example)
+			}
+		}
+
+		mixed := classifier.WithModel(TrainNaiveBayes(training))
+		for _, example := range testing {
+			result := mixed.Classify(example.Title, example.Flair, example.Body)
+			report.add(result.Giveaway, example.Giveaway)
+		}
+	}
+
+	return report
+}
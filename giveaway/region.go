@@ -0,0 +1,152 @@
+package giveaway
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Region is a shipping region of a giveaway, or the region of a participant
+type Region string
+
+const (
+	Worldwide Region = "worldwide"
+	US        Region = "us"
+	// CONUS is the contiguous United States (without Alaska and Hawaii)
+	CONUS  Region = "conus"
+	Canada Region = "canada"
+	EU     Region = "eu"
+	UK     Region = "uk"
+	// Other is the region of the participants living anywhere else
+	Other Region = "other"
+)
+
+// Regions are the regions a participant may choose
+var Regions = []Region{US, CONUS, Canada, EU, UK, Other}
+
+// ParseRegion returns the Region named `name`, or false if it isn't a region
+// a participant may choose
+func ParseRegion(name string) (Region, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, region := range Regions {
+		if string(region) == name {
+			return region, true
+		}
+	}
+	return "", false
+}
+
+// regionPattern matches the mentions of a region in a text. Short names that
+// are also common words ("US", "CA") are only accepted in upper case.
+type regionPattern struct {
+	regions []Region
+	rx      *regexp.Regexp
+}
+
+var regionPatterns = []regionPattern{
+	{[]Region{Worldwide}, regexp.MustCompile(`(?i)\b(world ?wide|ww|international(ly)?|global(ly)?|ships? anywhere|open to (all|everyone|anyone)|any ?where in the world)\b`)},
+	{[]Region{CONUS}, regexp.MustCompile(`(?i)\b(conus|contiguous (us|usa|u\.s\.|united states|states)|lower 48|continental (us|usa|u\.s\.|united states))\b`)},
+	{[]Region{US}, regexp.MustCompile(`\b(US|USA|U\.S\.A?\.?)(\b|$)|(?i)\b(united states|america)\b`)},
+	{[]Region{US, Canada}, regexp.MustCompile(`\bNA\b|(?i)\bnorth america\b`)},
+	{[]Region{Canada}, regexp.MustCompile(`\bCA(N|NADA)?\b|(?i)\bcanada\b`)},
+	{[]Region{EU}, regexp.MustCompile(`(?i)\b(eu|europe|european union)\b`)},
+	{[]Region{UK}, regexp.MustCompile(`(?i)\b(uk|u\.k\.|united kingdom|great britain|england)\b`)},
+}
+
+// exclusionRx matches the words that exclude the region following them
+var exclusionRx = regexp.MustCompile(`(?i)\b(except|excluding|excl\.?|not (to|in|for)|no|sorry)\s+(the\s+)?$`)
+
+// Eligibility tells which regions a giveaway is open to
+type Eligibility struct {
+	// Regions are the regions explicitly open to the giveaway. Empty if the
+	// post does not mention any region.
+	Regions []Region
+	// Excluded are the regions explicitly excluded (e.g. "worldwide except
+	// EU")
+	Excluded []Region
+}
+
+// ParseEligibility looks for the regions a giveaway is open to in its title,
+// link flair and selftext.
+func ParseEligibility(title, flair, body string) Eligibility {
+	var eligibility Eligibility
+	found := make(map[Region]bool)
+
+	text := title + "\n" + flair + "\n" + body
+	for _, pattern := range regionPatterns {
+		for _, loc := range pattern.rx.FindAllStringIndex(text, -1) {
+			excluded := exclusionRx.MatchString(text[:loc[0]])
+
+			for _, region := range pattern.regions {
+				if found[region] {
+					continue
+				}
+				found[region] = true
+
+				if excluded {
+					eligibility.Excluded = append(eligibility.Excluded, region)
+				} else {
+					eligibility.Regions = append(eligibility.Regions, region)
+				}
+			}
+		}
+	}
+
+	return eligibility
+}
+
+// Known returns true if the post mentions which regions it is open to
+func (e Eligibility) Known() bool {
+	return len(e.Regions) > 0
+}
+
+// Allows returns true if a participant from `region` may enter the giveaway.
+// Giveaways without any region are assumed to be open to everyone. A
+// participant from the US is assumed to live in the contiguous US.
+func (e Eligibility) Allows(region Region) bool {
+	for _, excluded := range e.Excluded {
+		if matches(excluded, region) {
+			return false
+		}
+	}
+
+	if !e.Known() {
+		return true
+	}
+
+	for _, eligible := range e.Regions {
+		if eligible == Worldwide || matches(eligible, region) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches returns true if `region` is part of `target`
+func matches(target, region Region) bool {
+	if target == region {
+		return true
+	}
+	// assume the participants of the US live in the contiguous US
+	return (target == US && region == CONUS) || (target == CONUS && region == US)
+}
+
+// String formats the eligibility (e.g. "worldwide except eu", "us, canada")
+func (e Eligibility) String() string {
+	join := func(regions []Region) string {
+		names := make([]string, len(regions))
+		for i, region := range regions {
+			names[i] = string(region)
+		}
+		return strings.Join(names, ", ")
+	}
+
+	description := "unknown"
+	if e.Known() {
+		description = join(e.Regions)
+	}
+	if len(e.Excluded) > 0 {
+		description += " except " + join(e.Excluded)
+	}
+	return description
+}
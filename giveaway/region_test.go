@@ -0,0 +1,44 @@
+package giveaway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEligibility(t *testing.T) {
+	cases := []struct {
+		title, flair, body string
+		regions, excluded  []Region
+	}{
+		{"[Giveaway] GMK keycaps [US only]", "", "", []Region{US}, nil},
+		{"Giveaway! Worldwide", "", "", []Region{Worldwide}, nil},
+		{"Deskmat giveaway", "Giveaway - CONUS", "", []Region{CONUS}, nil},
+		{"Keyboard giveaway", "", "Open to the EU and the UK.", []Region{EU, UK}, nil},
+		{"Giveaway (WW except EU)", "", "", []Region{Worldwide}, []Region{EU}},
+		{"Giveaway for all of us", "", "ships to North America", []Region{US, Canada}, nil},
+		{"Giveaway", "", "thanks to all of us, can't wait", nil, nil},
+	}
+
+	for _, c := range cases {
+		eligibility := ParseEligibility(c.title, c.flair, c.body)
+		assert.ElementsMatch(t, c.regions, eligibility.Regions, c.title)
+		assert.ElementsMatch(t, c.excluded, eligibility.Excluded, c.title)
+	}
+}
+
+func TestEligibilityAllows(t *testing.T) {
+	usOnly := Eligibility{Regions: []Region{US}}
+	assert.True(t, usOnly.Allows(US))
+	assert.True(t, usOnly.Allows(CONUS))
+	assert.False(t, usOnly.Allows(EU))
+
+	worldwide := Eligibility{Regions: []Region{Worldwide}, Excluded: []Region{EU}}
+	assert.True(t, worldwide.Allows(Other))
+	assert.False(t, worldwide.Allows(EU))
+
+	unknown := Eligibility{}
+	assert.True(t, unknown.Allows(UK))
+	assert.Equal(t, "unknown", unknown.String())
+	assert.Equal(t, "worldwide except eu", worldwide.String())
+}
@@ -1,4 +1,8 @@
-// mkgiveawaynotifier is divided into two sub-module :
+// mkgiveawaynotifier is divided into six sub-module :
 // reddit which handle communication with the Reddit API,
+// giveaway which extracts information from the posts,
+// duration which parses the durations written by the users,
+// filter which parses the filter expressions of the subscriptions,
+// sink which delivers the notifications (Telegram, Discord, ...),
 // telegram which handle the reception/reply of messages.
 package mkgiveawaynotifier
@@ -0,0 +1,236 @@
+package reddit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vartanbeno/go-reddit/v2/reddit"
+)
+
+// FakePost is an entry of the script of a FakeBot
+type FakePost struct {
+	// After is the delay after which the post is published, relative to the
+	// creation of the FakeBot (e.g. "10m", negative delays are in the past).
+	// The post is published from the start if it is empty.
+	After     string `json:"after"`
+	Title     string `json:"title"`
+	Author    string `json:"author"`
+	Subreddit string `json:"subreddit"`
+	Body      string `json:"selftext"`
+	Flair     string `json:"link_flair_text"`
+}
+
+// FakeBot is an in-memory Fetcher which plays back scripted posts : a post is
+// visible once its creation date is reached. It never accesses the network so
+// it can be used to run the bots offline.
+type FakeBot struct {
+	mutex *sync.Mutex
+	// all scripted posts, newest first
+	posts []*Post
+	now   func() time.Time
+}
+
+// NewFakeBot returns a FakeBot playing back `posts`, the creation date of the
+// posts is used to decide when they are visible. The FullID of the posts are
+// generated if missing, and the posts without creation date are visible from
+// the start.
+func NewFakeBot(posts []*Post) *FakeBot {
+	start := time.Now()
+	sorted := make([]*Post, len(posts))
+	copy(sorted, posts)
+	for _, post := range sorted {
+		if post.Created == nil {
+			post.Created = &reddit.Timestamp{Time: start}
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Created.Time.After(sorted[j].Created.Time)
+	})
+
+	for i, post := range sorted {
+		if post.ID == "" {
+			post.ID = fmt.Sprintf("fake%d", len(sorted)-i)
+		}
+		if post.FullID == "" {
+			post.FullID = "t3_" + post.ID
+		}
+		if post.Permalink == "" {
+			post.Permalink = fmt.Sprintf("/r/%s/comments/%s/", post.SubredditName, post.ID)
+		}
+	}
+
+	return &FakeBot{
+		mutex: &sync.Mutex{},
+		posts: sorted,
+		now:   time.Now,
+	}
+}
+
+// LoadFakeBot returns a FakeBot playing back the script stored at `path`, a
+// JSON list of FakePost.
+func LoadFakeBot(path string) (*FakeBot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var script []FakePost
+	err = json.Unmarshal(data, &script)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	posts := make([]*Post, len(script))
+	for i, entry := range script {
+		var after time.Duration
+		if len(entry.After) > 0 {
+			after, err = time.ParseDuration(entry.After)
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: %w", i, err)
+			}
+		}
+
+		posts[i] = &Post{
+			Post: reddit.Post{
+				Created:       &reddit.Timestamp{Time: start.Add(after)},
+				Title:         entry.Title,
+				Author:        entry.Author,
+				SubredditName: entry.Subreddit,
+				Body:          entry.Body,
+				IsSelfPost:    true,
+			},
+			Flair: entry.Flair,
+		}
+	}
+
+	return NewFakeBot(posts), nil
+}
+
+// visible returns the published posts of the subreddits, newest first
+func (bot *FakeBot) visible(subreddits string) []*Post {
+	bot.mutex.Lock()
+	defer bot.mutex.Unlock()
+
+	names := make(map[string]bool)
+	for _, name := range strings.Split(subreddits, "+") {
+		names[strings.ToLower(name)] = true
+	}
+
+	now := bot.now()
+	var posts []*Post
+	for _, post := range bot.posts {
+		if post.Created.Time.After(now) {
+			continue
+		}
+		if post.SubredditName != "" && !names[strings.ToLower(post.SubredditName)] {
+			continue
+		}
+		posts = append(posts, post)
+	}
+	return posts
+}
+
+// CheckSubredditContext accepts the subreddits of the scripted posts, and any
+// valid name if some posts have no subreddit
+func (bot *FakeBot) CheckSubredditContext(ctx context.Context, name string) (string, error) {
+	name = TrimSubredditName(name)
+	if !subredditNameRx.MatchString(name) {
+		return "", &SubredditError{name, SubredditInvalid}
+	}
+
+	bot.mutex.Lock()
+	defer bot.mutex.Unlock()
+
+	for _, post := range bot.posts {
+		if post.SubredditName == "" {
+			return name, nil
+		}
+		if strings.EqualFold(post.SubredditName, name) {
+			return post.SubredditName, nil
+		}
+	}
+	return "", &SubredditError{name, SubredditNotFound}
+}
+
+// NewFeedContext creates a Feed touched on the published posts
+func (bot *FakeBot) NewFeedContext(ctx context.Context, subreddits ...string) (*Feed, error) {
+	if len(subreddits) < 1 {
+		return nil, fmt.Errorf("at least 1 subreddit is required to create a Feed")
+	}
+
+	feed := &Feed{Subreddits: strings.Join(subreddits, "+")}
+
+	_, err := bot.TouchContext(ctx, feed)
+	if err != nil {
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+// TouchContext sets the anchor of the feed to the most recent published posts
+func (bot *FakeBot) TouchContext(ctx context.Context, feed *Feed) ([]*Post, error) {
+	limit := 5
+	return fetchAndUpdateAnchor(feed, limit, func() ([]*Post, error) {
+		posts := bot.visible(feed.Subreddits)
+		if len(posts) > limit {
+			posts = posts[:limit]
+		}
+		return posts, nil
+	})
+}
+
+// PeekContext returns the published posts newer than the anchor of the feed
+func (bot *FakeBot) PeekContext(ctx context.Context, feed *Feed) ([]*Post, error) {
+	if len(feed.Anchor) == 0 {
+		return nil, EmptyAnchorError{}
+	}
+
+	posts := bot.visible(feed.Subreddits)
+
+	// newest anchor position still present
+	for _, position := range feed.Anchor {
+		for i, post := range posts {
+			if post.FullID == position.FullID {
+				return posts[:i], nil
+			}
+		}
+	}
+
+	// no position found, use the date instead
+	return bot.newerThan(posts, minOfAnchor(feed.Anchor)), nil
+}
+
+// UpdateContext is like PeekContext but moves the anchor of the feed
+func (bot *FakeBot) UpdateContext(ctx context.Context, feed *Feed) ([]*Post, error) {
+	return bot.UpdateForAnchorSizeContext(ctx, feed, len(feed.Anchor))
+}
+
+// UpdateForAnchorSizeContext is like UpdateContext but resizes the anchor
+func (bot *FakeBot) UpdateForAnchorSizeContext(ctx context.Context, feed *Feed, anchorSize int) ([]*Post, error) {
+	return fetchAndUpdateAnchor(feed, anchorSize, func() ([]*Post, error) {
+		return bot.PeekContext(ctx, feed)
+	})
+}
+
+// PollContext returns the published posts newer than `duration`
+func (bot *FakeBot) PollContext(ctx context.Context, feed *Feed, duration time.Duration) ([]*Post, error) {
+	return bot.newerThan(bot.visible(feed.Subreddits), bot.now().Add(-duration)), nil
+}
+
+// newerThan returns the prefix of `posts` (newest first) created after target
+func (bot *FakeBot) newerThan(posts []*Post, target time.Time) []*Post {
+	for i, post := range posts {
+		if !post.Created.Time.After(target) {
+			return posts[:i]
+		}
+	}
+	return posts
+}
@@ -0,0 +1,104 @@
+package reddit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vartanbeno/go-reddit/v2/reddit"
+)
+
+func getFakeBot(now *time.Time) *FakeBot {
+	var posts []*Post
+	for d := -10; d < 10; d++ {
+		t := &reddit.Timestamp{Time: now.Add(time.Minute * time.Duration(d))}
+		posts = append(posts, &Post{Post: reddit.Post{Created: t, SubredditName: "MechanicalKeyboards"}})
+	}
+	// another subreddit, should never be visible
+	posts = append(posts, &Post{Post: reddit.Post{Created: &reddit.Timestamp{Time: now.Add(-time.Hour)}, SubredditName: "mechmarket"}})
+
+	bot := NewFakeBot(posts)
+	bot.now = func() time.Time { return *now }
+	return bot
+}
+
+func TestFakeBotUpdate(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	bot := getFakeBot(&now)
+
+	feed, err := bot.NewFeedContext(ctx, "MechanicalKeyboards")
+	assert.NoError(t, err)
+	assert.Len(t, feed.Anchor, 5)
+
+	posts, err := bot.UpdateContext(ctx, feed)
+	assert.NoError(t, err)
+	assert.Empty(t, posts)
+
+	// publish 3 posts
+	now = now.Add(3 * time.Minute)
+
+	posts, err = bot.PeekContext(ctx, feed)
+	assert.NoError(t, err)
+	assert.Len(t, posts, 3)
+
+	posts, err = bot.UpdateContext(ctx, feed)
+	assert.NoError(t, err)
+	assert.Len(t, posts, 3)
+	assert.Equal(t, posts[0].FullID, feed.Anchor[0].FullID)
+	assert.Len(t, feed.Anchor, 5)
+
+	posts, err = bot.UpdateContext(ctx, feed)
+	assert.NoError(t, err)
+	assert.Empty(t, posts)
+}
+
+func TestFakeBotPoll(t *testing.T) {
+	now := time.Now()
+	bot := getFakeBot(&now)
+
+	posts, err := bot.PollContext(context.Background(), &Feed{Subreddits: "MechanicalKeyboards"}, 150*time.Second)
+	assert.NoError(t, err)
+	// -2min, -1min and now
+	assert.Len(t, posts, 3)
+
+	posts, err = bot.PollContext(context.Background(), &Feed{Subreddits: "mechmarket"}, 2*time.Hour)
+	assert.NoError(t, err)
+	assert.Len(t, posts, 1)
+}
+
+func TestFakeBotCheckSubreddit(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	bot := getFakeBot(&now)
+
+	name, err := bot.CheckSubredditContext(ctx, "r/mechmarket")
+	assert.NoError(t, err)
+	assert.Equal(t, "mechmarket", name)
+
+	_, err = bot.CheckSubredditContext(ctx, "keyboards")
+	assert.Equal(t, &SubredditError{"keyboards", SubredditNotFound}, err)
+}
+
+func TestFakeBotWithoutCreated(t *testing.T) {
+	ctx := context.Background()
+
+	// the posts without creation date are visible from the start
+	bot := NewFakeBot([]*Post{{}, {Post: reddit.Post{Created: &reddit.Timestamp{Time: time.Now().Add(-time.Minute)}}}})
+	posts, err := bot.PollContext(ctx, &Feed{Subreddits: "MechanicalKeyboards"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Len(t, posts, 2)
+	assert.NotNil(t, posts[0].Created)
+
+	path := filepath.Join(t.TempDir(), "script.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`[{"title": "GMK giveaway"}, {"title": "later", "after": "1h"}]`), 0644))
+	bot, err = LoadFakeBot(path)
+	assert.NoError(t, err)
+	posts, err = bot.PollContext(ctx, &Feed{Subreddits: "MechanicalKeyboards"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Len(t, posts, 1)
+	assert.Equal(t, "GMK giveaway", posts[0].Title)
+}
@@ -1,6 +1,7 @@
 package reddit
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -34,13 +35,18 @@ type Feed struct {
 // a valid anchor. Calling Bot.Peek(*Feed) or Bot.Update(*Feed) right
 // after creation might return an empty list if the sub isn't very active.
 func (bot *Bot) NewFeed(subreddits ...string) (*Feed, error) {
+	return bot.NewFeedContext(context.Background(), subreddits...)
+}
+
+// NewFeedContext is like NewFeed with a context.
+func (bot *Bot) NewFeedContext(ctx context.Context, subreddits ...string) (*Feed, error) {
 	if len(subreddits) < 1 {
 		return nil, fmt.Errorf("at least 1 subreddit is required to create a Feed")
 	}
 
 	feed := &Feed{Subreddits: strings.Join(subreddits, "+")}
 
-	_, err := bot.Touch(feed)
+	_, err := bot.TouchContext(ctx, feed)
 	if err != nil {
 		return nil, err
 	}
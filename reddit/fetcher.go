@@ -0,0 +1,22 @@
+package reddit
+
+import (
+	"context"
+	"time"
+)
+
+// Fetcher gathers the operations on a Feed. It is implemented by *Bot, which
+// calls the reddit API, and by *FakeBot which plays back scripted posts.
+// See the corresponding methods of Bot for the documentation.
+type Fetcher interface {
+	CheckSubredditContext(ctx context.Context, name string) (string, error)
+	NewFeedContext(ctx context.Context, subreddits ...string) (*Feed, error)
+	TouchContext(ctx context.Context, feed *Feed) ([]*Post, error)
+	PeekContext(ctx context.Context, feed *Feed) ([]*Post, error)
+	UpdateContext(ctx context.Context, feed *Feed) ([]*Post, error)
+	UpdateForAnchorSizeContext(ctx context.Context, feed *Feed, anchorSize int) ([]*Post, error)
+	PollContext(ctx context.Context, feed *Feed, duration time.Duration) ([]*Post, error)
+}
+
+var _ Fetcher = (*Bot)(nil)
+var _ Fetcher = (*FakeBot)(nil)
@@ -1,6 +1,7 @@
 package reddit
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -9,42 +10,189 @@ import (
 
 const minRemaining = 2
 
+// number of requests allowed by reddit per 10 minutes window
+const (
+	readonlyBudget      = 300
+	authenticatedBudget = 600
+)
+
+// window is the duration after which reddit resets the rate
+const window = 10 * time.Minute
+
+// Budget is the state of the rate limiter of a Bot
+type Budget struct {
+	// Limit is the number of requests allowed per window
+	Limit int
+	// Remaining is the number of requests left in the current window
+	Remaining int
+	// Used is the number of requests made in the current window
+	Used int
+	// Reset is the end of the current window
+	Reset time.Time
+	// Waiting is the number of requests waiting for the next window
+	Waiting int
+}
+
 // rate limiter for the reddit API
 // This ratelimiter works with best effort : there is no way to know if another
 // client is using the same identifiers so the actual number of remaining calls
 // may be lower than estimated.
+// It works as a token bucket refilled at the end of each window, the state of
+// the bucket is corrected by the headers of each response (see Update). Callers
+// that have to wait are served in their order of arrival.
 type ratelimiter struct {
 	mutex *sync.Mutex
 	rate  reddit.Rate
+	// number of requests allowed per window
+	budget int
+	// waiting callers, oldest first : the channel is closed once a slot is
+	// reserved for the caller
+	queue []chan struct{}
+	// timer waking up the queue at the end of the window
+	timer *time.Timer
 }
 
-// newRateLimiter return a new, valid ratelimiter
-func newRateLimiter() *ratelimiter {
-	// avoid sleeping on invalid datetime for the first call
+// newRateLimiter return a new, valid ratelimiter allowing `budget` requests per
+// window until the API tells otherwise
+func newRateLimiter(budget int) *ratelimiter {
 	return &ratelimiter{
-		mutex: &sync.Mutex{},
-		rate:  reddit.Rate{Remaining: minRemaining + 1},
+		mutex:  &sync.Mutex{},
+		rate:   reddit.Rate{Remaining: budget, Reset: time.Now().Add(window)},
+		budget: budget,
 	}
 }
 
 // Book reserves a slot, waiting if necessary to avoid going
-// over the limit of the API.
-func (rl *ratelimiter) Book() {
+// over the limit of the API. The wait is interrupted if ctx is done, in which
+// case the error of the context is returned and no slot is reserved.
+func (rl *ratelimiter) Book(ctx context.Context) error {
+	rl.mutex.Lock()
+
+	rl.roll()
+	if len(rl.queue) == 0 && rl.available() {
+		rl.take()
+		rl.mutex.Unlock()
+		return nil
+	}
+
+	ready := make(chan struct{})
+	rl.queue = append(rl.queue, ready)
+	rl.schedule()
+
+	rl.mutex.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+
 	rl.mutex.Lock()
 	defer rl.mutex.Unlock()
 
-	if rl.rate.Remaining < minRemaining {
-		time.Sleep(time.Until(rl.rate.Reset))
-		rl.rate.Remaining = 300
+	for i, waiter := range rl.queue {
+		if waiter == ready {
+			rl.queue = append(rl.queue[:i], rl.queue[i+1:]...)
+			return ctx.Err()
+		}
 	}
 
-	rl.rate.Remaining -= 1
+	// the slot was reserved in the meantime : give it to the next caller
+	rl.rate.Remaining++
+	rl.rate.Used--
+	rl.dispatch()
+
+	return ctx.Err()
 }
 
 // Update sets the information of the ratelimiter to more up to date information
 func (rl *ratelimiter) Update(rate reddit.Rate) {
+	// no header in the response
+	if rate.Reset.IsZero() {
+		return
+	}
+
 	rl.mutex.Lock()
 	defer rl.mutex.Unlock()
 
 	rl.rate = rate
+
+	// the headers give the budget of the current window
+	if budget := rate.Used + rate.Remaining; budget > 0 {
+		rl.budget = budget
+	}
+
+	// the reset may have changed
+	if rl.timer != nil {
+		rl.timer.Stop()
+		rl.timer = nil
+	}
+	rl.dispatch()
+}
+
+// Budget returns the current state of the ratelimiter
+func (rl *ratelimiter) Budget() Budget {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	rl.roll()
+	return Budget{
+		Limit:     rl.budget,
+		Remaining: rl.rate.Remaining,
+		Used:      rl.rate.Used,
+		Reset:     rl.rate.Reset,
+		Waiting:   len(rl.queue),
+	}
+}
+
+// available returns true if a slot can be reserved, the mutex must be held
+func (rl *ratelimiter) available() bool {
+	return rl.rate.Remaining >= minRemaining
+}
+
+// take reserves a slot, the mutex must be held
+func (rl *ratelimiter) take() {
+	rl.rate.Remaining--
+	rl.rate.Used++
+}
+
+// roll starts a new window if the current one is over, the mutex must be held
+func (rl *ratelimiter) roll() {
+	now := time.Now()
+	if now.Before(rl.rate.Reset) {
+		return
+	}
+
+	rl.rate = reddit.Rate{
+		Remaining: rl.budget,
+		Reset:     now.Add(window),
+	}
+}
+
+// dispatch reserves slots for the waiting callers in order, the mutex must
+// be held
+func (rl *ratelimiter) dispatch() {
+	rl.roll()
+	for len(rl.queue) > 0 && rl.available() {
+		rl.take()
+		close(rl.queue[0])
+		rl.queue = rl.queue[1:]
+	}
+	rl.schedule()
+}
+
+// schedule wakes up the queue at the end of the window if some callers are
+// waiting, the mutex must be held
+func (rl *ratelimiter) schedule() {
+	if len(rl.queue) == 0 || rl.timer != nil {
+		return
+	}
+
+	rl.timer = time.AfterFunc(time.Until(rl.rate.Reset), func() {
+		rl.mutex.Lock()
+		defer rl.mutex.Unlock()
+
+		rl.timer = nil
+		rl.dispatch()
+	})
 }
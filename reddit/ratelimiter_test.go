@@ -0,0 +1,70 @@
+package reddit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vartanbeno/go-reddit/v2/reddit"
+)
+
+func TestRateLimiterBook(t *testing.T) {
+	rl := newRateLimiter(10)
+
+	for i := 0; i < 10-minRemaining+1; i++ {
+		assert.NoError(t, rl.Book(context.Background()))
+	}
+
+	budget := rl.Budget()
+	assert.Equal(t, 10, budget.Limit)
+	assert.Equal(t, minRemaining-1, budget.Remaining)
+	assert.Equal(t, 10-minRemaining+1, budget.Used)
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	rl := newRateLimiter(10)
+	rl.Update(reddit.Rate{Remaining: 0, Used: 10, Reset: time.Now().Add(time.Hour)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, rl.Book(ctx))
+	assert.Equal(t, 0, rl.Budget().Waiting)
+}
+
+func TestRateLimiterFairness(t *testing.T) {
+	rl := newRateLimiter(10)
+	rl.Update(reddit.Rate{Remaining: 0, Used: 10, Reset: time.Now().Add(time.Hour)})
+
+	order := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		go func(i int) {
+			assert.NoError(t, rl.Book(context.Background()))
+			order <- i
+		}(i)
+
+		// make sure the callers are queued in order
+		for rl.Budget().Waiting != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// one slot at a time, released in the order of arrival
+	for i := 0; i < 3; i++ {
+		rl.Update(reddit.Rate{Remaining: minRemaining, Used: 10, Reset: time.Now().Add(time.Hour)})
+		assert.Equal(t, i, <-order)
+	}
+	assert.Equal(t, 0, rl.Budget().Waiting)
+}
+
+func TestRateLimiterReset(t *testing.T) {
+	rl := newRateLimiter(10)
+	rl.Update(reddit.Rate{Remaining: 0, Used: 10, Reset: time.Now().Add(20 * time.Millisecond)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.NoError(t, rl.Book(ctx))
+	assert.Equal(t, 9, rl.Budget().Remaining)
+}
@@ -1,17 +1,27 @@
 // reddit handles communication with the Reddit API.
 // To obtain a bot, you can call DefaultBot() which
-// returns a bot without any login information.
+// returns a bot without any login information, or
+// NewRedditBotWithCredentials(Credentials) to login
+// with a script application and get a higher rate.
 // You can then create a new Feed via the Bot.NewFeed(...string)
 // method for a list of subreddit. This method requires an internet
 // connection to fetch reddit's API and setup then Anchor of the Feed.
 // Feed can be Marshal'ed/Unmarshal'ed although it is not necessary to do
 // it manually : the telegram API does it.
+// Every method of the Bot accessing the network has a variant taking a
+// context.Context (e.g. Bot.UpdateContext) which can be used to cancel
+// it, the other variants use context.Background().
 package reddit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/vartanbeno/go-reddit/v2/reddit"
@@ -19,6 +29,9 @@ import (
 
 var defaultBot *Bot = NewRedditBot()
 
+// requestTimeout is the maximum duration of a single request to the reddit API
+const requestTimeout = 30 * time.Second
+
 // DefaultBot returns a bot without any login information.
 // The rate of this bot will be limited to 300 requests / 10min per
 // Reddit's API.
@@ -28,12 +41,22 @@ func DefaultBot() *Bot {
 }
 
 // Post represent a reddit post with Title, Author, etc
-type Post = reddit.Post
+type Post struct {
+	reddit.Post
+	// Flair is the text of the link flair of the post
+	Flair string `json:"link_flair_text,omitempty"`
+}
+
+// Credentials of a reddit script application (client id & secret) and of the
+// account using it (username & password)
+type Credentials = reddit.Credentials
 
 // Bot wraps around github.com/vartanbeno/go-reddit/v2/reddit with a rate limiter
+// and retries the requests failing with a temporary error.
 type Bot struct {
 	client      *reddit.Client
 	ratelimiter *ratelimiter
+	retry       RetryPolicy
 }
 
 // NewRedditBot creates a reddit API handles without any login information.
@@ -42,77 +65,210 @@ type Bot struct {
 func NewRedditBot() *Bot {
 	// will not fail without argument
 	client, _ := reddit.NewReadonlyClient()
+	return newBot(client, readonlyBudget)
+}
+
+// NewRedditBotWithCredentials creates a reddit API handle logged in with
+// the credentials of a script application. The access token is fetched with
+// the first request and refreshed by the client when it expires.
+// The rate of this bot will be limited to 600 requests / 10min per
+// Reddit's API.
+func NewRedditBotWithCredentials(credentials Credentials) (*Bot, error) {
+	if credentials.ID == "" || credentials.Secret == "" {
+		return nil, fmt.Errorf("client id and secret are required")
+	}
+	if credentials.Username == "" || credentials.Password == "" {
+		return nil, fmt.Errorf("username and password are required")
+	}
+
+	client, err := reddit.NewClient(credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBot(client, authenticatedBudget), nil
+}
+
+// newBot returns a bot using `client` with `budget` requests per window
+func newBot(client *reddit.Client, budget int) *Bot {
+	client.OnRequestCompleted(captureReason)
 	return &Bot{
-		client,
-		newRateLimiter(),
+		client:      client,
+		ratelimiter: newRateLimiter(budget),
+		retry:       DefaultRetryPolicy,
 	}
 }
 
-// newPosts fetches new posts using the rate limiter
-func (bot Bot) newPosts(subreddit, before, after string, limit int) ([]*reddit.Post, error) {
-	bot.ratelimiter.Book()
+// SetRetryPolicy changes how the failed requests are retried. It must not be
+// called while the bot is in use.
+func (bot *Bot) SetRetryPolicy(policy RetryPolicy) {
+	bot.retry = policy
+}
+
+// RetryPolicy returns how the failed requests are retried
+func (bot *Bot) RetryPolicy() RetryPolicy {
+	return bot.retry
+}
+
+// Budget returns the current state of the rate limiter of the bot
+func (bot *Bot) Budget() Budget {
+	return bot.ratelimiter.Budget()
+}
+
+// newPosts fetches new posts using the rate limiter. This is the request of
+// Subreddit.NewPosts but the flair of the posts is decoded too.
+func (bot Bot) newPosts(ctx context.Context, subreddit, before, after string, limit int) ([]*Post, error) {
+	query := url.Values{}
+	if before != "" {
+		query.Set("before", before)
+	}
+	if after != "" {
+		query.Set("after", after)
+	}
+	if limit != 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var listing struct {
+		Data struct {
+			Children []struct {
+				Data *Post `json:"data"`
+			} `json:"children"`
+		} `json:"data"`
+	}
 
-	posts, resp, err := bot.client.Subreddit.NewPosts(context.Background(), subreddit, &reddit.ListOptions{
-		After:  after,
-		Before: before,
-		Limit:  limit,
+	err := bot.do(ctx, func(ctx context.Context) (*reddit.Response, error) {
+		req, err := bot.client.NewRequest(http.MethodGet, fmt.Sprintf("r/%s/new?%s", subreddit, query.Encode()), nil)
+		if err != nil {
+			return nil, err
+		}
+		return bot.client.Do(ctx, req, &listing)
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	// set ratelimiter with newer information
-	bot.ratelimiter.Update(resp.Rate)
+	posts := make([]*Post, len(listing.Data.Children))
+	for i, child := range listing.Data.Children {
+		posts[i] = child.Data
+	}
 
 	return posts, nil
 }
 
-// getPost fetches the information of 1 post
-func (bot Bot) getPost(id string) (*reddit.Post, error) {
-	bot.ratelimiter.Book()
+// maxIDsPerRequest is the maximum number of posts fetched by id at once
+const maxIDsPerRequest = 100
 
-	posts, resp, err := bot.client.Listings.GetPosts(context.Background(), id)
+// postStatus holds the fields of a post telling if it is still visible
+type postStatus struct {
+	FullID            string  `json:"name"`
+	Author            string  `json:"author"`
+	Body              string  `json:"selftext"`
+	RemovedByCategory *string `json:"removed_by_category"`
+}
 
-	if err != nil {
-		return nil, err
+// alive returns true if the post was neither deleted by its author nor removed
+// by the moderators
+func (status postStatus) alive() bool {
+	if status.RemovedByCategory != nil {
+		return false
 	}
+	if status.Author == "[deleted]" {
+		return false
+	}
+	return status.Body != "[removed]" && status.Body != "[deleted]"
+}
 
-	// set ratelimiter with newer information
-	bot.ratelimiter.Update(resp.Rate)
+// postStatuses fetches the status of posts by their FullID, in a single
+// request per maxIDsPerRequest posts. This is the request of
+// Listings.GetPosts but the removal fields are decoded too. Posts unknown to
+// reddit are absent from the result.
+func (bot Bot) postStatuses(ctx context.Context, ids []string) (map[string]postStatus, error) {
+	statuses := make(map[string]postStatus, len(ids))
+
+	for low := 0; low < len(ids); low += maxIDsPerRequest {
+		high := low + maxIDsPerRequest
+		if high > len(ids) {
+			high = len(ids)
+		}
 
-	if len(posts) != 1 {
-		return nil, fmt.Errorf("expected 1 post for getPost(%s)", id)
+		var listing struct {
+			Data struct {
+				Children []struct {
+					Data postStatus `json:"data"`
+				} `json:"children"`
+			} `json:"data"`
+		}
+
+		err := bot.do(ctx, func(ctx context.Context) (*reddit.Response, error) {
+			req, err := bot.client.NewRequest(http.MethodGet, "by_id/"+strings.Join(ids[low:high], ","), nil)
+			if err != nil {
+				return nil, err
+			}
+			return bot.client.Do(ctx, req, &listing)
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, child := range listing.Data.Children {
+			statuses[child.Data.FullID] = child.Data
+		}
 	}
 
-	return posts[0], err
+	return statuses, nil
 }
 
-// checkPosition makes sure the position points to a valid, non-deleted post
-func (bot *Bot) checkPosition(postion Position) bool {
-	post, err := bot.getPost(postion.FullID)
+// checkPositions tells for each position of the anchor if it points to a
+// valid, non-deleted and non-removed post. An error is returned if the
+// validity could not be established.
+func (bot *Bot) checkPositions(ctx context.Context, anchor Anchor) ([]bool, error) {
+	ids := make([]string, len(anchor))
+	for i, position := range anchor {
+		ids[i] = position.FullID
+	}
+
+	statuses, err := bot.postStatuses(ctx, ids)
 
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) && reqErr.NotFound() {
+		// none of the posts exist anymore
+		return make([]bool, len(anchor)), nil
+	}
 	if err != nil {
-		return false
+		return nil, err
+	}
+
+	valid := make([]bool, len(anchor))
+	for i, position := range anchor {
+		status, found := statuses[position.FullID]
+		valid[i] = found && status.alive()
 	}
 
-	return post.Author != "[deleted]"
+	return valid, nil
 }
 
 // Touch sets the anchor of the feed to the most recent posts of the sub
-func (bot *Bot) Touch(feed *Feed) ([]*reddit.Post, error) {
+func (bot *Bot) Touch(feed *Feed) ([]*Post, error) {
+	return bot.TouchContext(context.Background(), feed)
+}
+
+// TouchContext is like Touch with a context.
+func (bot *Bot) TouchContext(ctx context.Context, feed *Feed) ([]*Post, error) {
 	limit := 5
-	return bot.fetchAndUpdateAnchor(feed, limit, func() ([]*reddit.Post, error) {
-		return bot.newPosts(feed.Subreddits, "", "", limit)
+	return fetchAndUpdateAnchor(feed, limit, func() ([]*Post, error) {
+		return bot.newPosts(ctx, feed.Subreddits, "", "", limit)
 	})
 }
 
-func (bot *Bot) peekBefore(subreddits, before string) ([]*reddit.Post, error) {
-	result := make(map[int][]*reddit.Post)
+func (bot *Bot) peekBefore(ctx context.Context, subreddits, before string) ([]*Post, error) {
+	result := make(map[int][]*Post)
 	totalLength := 0
 
 	for {
-		posts, err := bot.newPosts(subreddits, before, "", 100)
+		posts, err := bot.newPosts(ctx, subreddits, before, "", 100)
 
 		if err != nil {
 			return nil, err
@@ -132,7 +288,7 @@ func (bot *Bot) peekBefore(subreddits, before string) ([]*reddit.Post, error) {
 	}
 
 	// join all slices
-	joined := make([]*reddit.Post, totalLength)
+	joined := make([]*Post, totalLength)
 	low := 0
 	for k := len(result) - 1; k >= 0; k-- {
 		low += copy(joined[low:], result[k])
@@ -145,23 +301,32 @@ func (bot *Bot) peekBefore(subreddits, before string) ([]*reddit.Post, error) {
 // Feed.Anchor must have at least one element. See bot.Touch(*Feed) .
 // The returned posts are returned in newest-first order. This function may return
 // an empty list without error.
-func (bot *Bot) Peek(feed *Feed) ([]*reddit.Post, error) {
-	var results []*reddit.Post
-	var err error
+func (bot *Bot) Peek(feed *Feed) ([]*Post, error) {
+	return bot.PeekContext(context.Background(), feed)
+}
+
+// PeekContext is like Peek with a context.
+func (bot *Bot) PeekContext(ctx context.Context, feed *Feed) ([]*Post, error) {
+	var results []*Post
 
 	// if no anchor available, impossible to have a reference in the feed
 	if len(feed.Anchor) == 0 {
 		return nil, EmptyAnchorError{}
 	}
 
+	valid, err := bot.checkPositions(ctx, feed.Anchor)
+	if err != nil {
+		return nil, err
+	}
+
 	// try all anchor points, newest first
-	for _, position := range feed.Anchor {
-		if !bot.checkPosition(position) {
+	for i, position := range feed.Anchor {
+		if !valid[i] {
 			log.Printf("invalid index for position %+v\n", position)
 			continue
 		}
 
-		results, err = bot.peekBefore(feed.Subreddits, position.FullID)
+		results, err = bot.peekBefore(ctx, feed.Subreddits, position.FullID)
 
 		if err != nil {
 			return nil, err
@@ -174,7 +339,7 @@ func (bot *Bot) Peek(feed *Feed) ([]*reddit.Post, error) {
 
 	// unable to fetch from the anchor, crawl to saved date instead
 	if len(results) == 0 {
-		return bot.crawl(feed)
+		return bot.crawl(ctx, feed)
 	}
 
 	// result looks like {optionalNewPost, anchor[0], anchor[1], ...}
@@ -202,17 +367,31 @@ func (bot *Bot) Peek(feed *Feed) ([]*reddit.Post, error) {
 // The returned posts are returned in newest-first order. This function may return
 // an empty list without error.
 // Feed.Anchor is not written to in case of any error.
-func (bot *Bot) Update(feed *Feed) ([]*reddit.Post, error) {
-	return bot.UpdateForAnchorSize(feed, len(feed.Anchor))
+func (bot *Bot) Update(feed *Feed) ([]*Post, error) {
+	return bot.UpdateContext(context.Background(), feed)
+}
+
+// UpdateContext is like Update with a context.
+func (bot *Bot) UpdateContext(ctx context.Context, feed *Feed) ([]*Post, error) {
+	return bot.UpdateForAnchorSizeContext(ctx, feed, len(feed.Anchor))
 }
 
-func (bot *Bot) UpdateForAnchorSize(feed *Feed, anchorSize int) ([]*reddit.Post, error) {
-	return bot.fetchAndUpdateAnchor(feed, anchorSize, func() ([]*reddit.Post, error) {
-		return bot.Peek(feed)
+// UpdateForAnchorSize is like Update but the anchor of the feed is resized to
+// anchorSize.
+func (bot *Bot) UpdateForAnchorSize(feed *Feed, anchorSize int) ([]*Post, error) {
+	return bot.UpdateForAnchorSizeContext(context.Background(), feed, anchorSize)
+}
+
+// UpdateForAnchorSizeContext is like UpdateForAnchorSize with a context.
+func (bot *Bot) UpdateForAnchorSizeContext(ctx context.Context, feed *Feed, anchorSize int) ([]*Post, error) {
+	return fetchAndUpdateAnchor(feed, anchorSize, func() ([]*Post, error) {
+		return bot.PeekContext(ctx, feed)
 	})
 }
 
-func (bot *Bot) fetchAndUpdateAnchor(feed *Feed, anchorSize int, fetch func() ([]*reddit.Post, error)) ([]*reddit.Post, error) {
+// fetchAndUpdateAnchor calls fetch and sets the anchor of the feed to the
+// `anchorSize` newest fetched posts, completed by the old anchor if needed.
+func fetchAndUpdateAnchor(feed *Feed, anchorSize int, fetch func() ([]*Post, error)) ([]*Post, error) {
 	// get posts
 	posts, err := fetch()
 	if err != nil {
@@ -248,7 +427,7 @@ func (bot *Bot) fetchAndUpdateAnchor(feed *Feed, anchorSize int, fetch func() ([
 	return posts, nil
 }
 
-func (bot *Bot) crawl(feed *Feed) ([]*reddit.Post, error) {
+func (bot *Bot) crawl(ctx context.Context, feed *Feed) ([]*Post, error) {
 	// if no anchor available, impossible to have a reference in the feed
 	if len(feed.Anchor) == 0 {
 		return nil, EmptyAnchorError{}
@@ -257,17 +436,17 @@ func (bot *Bot) crawl(feed *Feed) ([]*reddit.Post, error) {
 	// minimum time of publication as a reference (-> oldest posts)
 	target := minOfAnchor(feed.Anchor)
 
-	return bot.crawlUntil(target, feed.Subreddits)
+	return bot.crawlUntil(ctx, target, feed.Subreddits)
 }
 
-func (bot *Bot) crawlUntil(target time.Time, subreddit string) ([]*reddit.Post, error) {
-	result := make(map[int][]*reddit.Post)
+func (bot *Bot) crawlUntil(ctx context.Context, target time.Time, subreddit string) ([]*Post, error) {
+	result := make(map[int][]*Post)
 	totalLength := 0
 
 	notFound := true
 	after := ""
 	for notFound {
-		posts, err := bot.newPosts(subreddit, "", after, 100)
+		posts, err := bot.newPosts(ctx, subreddit, "", after, 100)
 
 		if err != nil {
 			return nil, err
@@ -278,7 +457,7 @@ func (bot *Bot) crawlUntil(target time.Time, subreddit string) ([]*reddit.Post,
 			return nil, fmt.Errorf("unable to fetch 100 posts")
 		}
 
-		rm := newRollingMedian(posts[:5])
+		rm := newRollingMedian(rawPosts(posts[:5]))
 		if rm.cachedValue.Before(target) {
 			// break -> but save post firsts
 			notFound = false
@@ -287,7 +466,7 @@ func (bot *Bot) crawlUntil(target time.Time, subreddit string) ([]*reddit.Post,
 		}
 
 		for k := 5; k < len(posts); k++ {
-			if rm.add(posts[k]).Before(target) {
+			if rm.add(&posts[k].Post).Before(target) {
 				// break main loop -> but save post firsts
 				notFound = false
 				posts = posts[:k]
@@ -305,16 +484,22 @@ func (bot *Bot) crawlUntil(target time.Time, subreddit string) ([]*reddit.Post,
 	}
 
 	// join all slices -> newest post first
-	joined := make([]*reddit.Post, totalLength)
+	joined := make([]*Post, totalLength)
 	low := 0
-	for k := range result {
+	for k := 0; k < len(result); k++ {
 		low += copy(joined[low:], result[k])
 	}
 
 	return joined, nil
 }
 
-// Poll fetches the red dit API up to a date (ignoring any state anchor)
-func (bot *Bot) Poll(feed *Feed, duration time.Duration) ([]*reddit.Post, error) {
-	return bot.crawlUntil(time.Now().Add(-duration), feed.Subreddits)
+// Poll fetches the reddit API for all posts newer than `duration` (ignoring any
+// state anchor). The posts are returned in newest-first order.
+func (bot *Bot) Poll(feed *Feed, duration time.Duration) ([]*Post, error) {
+	return bot.PollContext(context.Background(), feed, duration)
+}
+
+// PollContext is like Poll with a context.
+func (bot *Bot) PollContext(ctx context.Context, feed *Feed, duration time.Duration) ([]*Post, error) {
+	return bot.crawlUntil(ctx, time.Now().Add(-duration), feed.Subreddits)
 }
@@ -0,0 +1,49 @@
+package reddit
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPositions(t *testing.T) {
+	calls := 0
+	bot := getTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.True(t, strings.Contains(r.URL.Path, "t3_a,t3_b,t3_c,t3_d,t3_e"))
+		w.Write([]byte(`{"kind": "Listing", "data": {"children": [
+			{"kind": "t3", "data": {"name": "t3_a", "author": "someone", "selftext": "", "removed_by_category": null}},
+			{"kind": "t3", "data": {"name": "t3_b", "author": "[deleted]", "selftext": "[deleted]"}},
+			{"kind": "t3", "data": {"name": "t3_c", "author": "someone", "selftext": "", "removed_by_category": "moderator"}},
+			{"kind": "t3", "data": {"name": "t3_d", "author": "someone", "selftext": "[removed]"}}
+		]}}`))
+	})
+
+	anchor := Anchor{{FullID: "t3_a"}, {FullID: "t3_b"}, {FullID: "t3_c"}, {FullID: "t3_d"}, {FullID: "t3_e"}}
+
+	valid, err := bot.checkPositions(context.Background(), anchor)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false, false, false, false}, valid)
+	assert.Equal(t, 1, calls)
+}
+
+func TestNewPostsFlair(t *testing.T) {
+	bot := getTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "5", r.URL.Query().Get("limit"))
+		assert.Equal(t, "t3_a", r.URL.Query().Get("before"))
+		w.Write([]byte(`{"kind": "Listing", "data": {"children": [
+			{"kind": "t3", "data": {"name": "t3_b", "title": "GMK Olivia", "created_utc": 1639576800, "link_flair_text": "Photos"}}
+		]}}`))
+	})
+
+	posts, err := bot.newPosts(context.Background(), "MechanicalKeyboards", "t3_a", "", 5)
+	assert.NoError(t, err)
+	assert.Len(t, posts, 1)
+	assert.Equal(t, "t3_b", posts[0].FullID)
+	assert.Equal(t, "GMK Olivia", posts[0].Title)
+	assert.Equal(t, "Photos", posts[0].Flair)
+	assert.Equal(t, int64(1639576800), posts[0].Created.Unix())
+}
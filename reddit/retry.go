@@ -0,0 +1,181 @@
+package reddit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/vartanbeno/go-reddit/v2/reddit"
+)
+
+// RetryPolicy describes how the requests to the reddit API are retried
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts per request, including
+	// the first one
+	MaxAttempts int
+	// BaseDelay is the delay before the second attempt, it doubles for each
+	// following attempt
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two attempts
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is the RetryPolicy of the bots created by this package
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+}
+
+// backoff returns the delay to wait after the attempt number `attempt` (the
+// first attempt has number 1) failed. The delay is randomized in [d/2, d) to
+// avoid synchronized retries.
+func (policy RetryPolicy) backoff(attempt int) time.Duration {
+	delay := policy.BaseDelay
+	for i := 1; i < attempt && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	if delay <= 1 {
+		return delay
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+}
+
+// RequestError is returned when a request to the reddit API failed, after
+// all attempts allowed by the RetryPolicy of the bot if the error was
+// temporary.
+type RequestError struct {
+	// Err is the error of the last attempt
+	Err error
+	// StatusCode is the HTTP status of the last attempt, zero if the request
+	// did not get any response
+	StatusCode int
+	// Attempts is the number of attempts made
+	Attempts int
+	// Retryable is true if the error is temporary (server errors, rate limit,
+	// timeouts) : the request may succeed later
+	Retryable bool
+}
+
+func (e *RequestError) Error() string {
+	kind := "permanent"
+	if e.Retryable {
+		kind = "temporary"
+	}
+	return fmt.Sprintf("%s error after %d attempt(s): %v", kind, e.Attempts, e.Err)
+}
+
+func (e *RequestError) Unwrap() error { return e.Err }
+
+// Temporary returns true if the request may succeed later
+func (e *RequestError) Temporary() bool { return e.Retryable }
+
+// NotFound returns true if the resource does not exist or is not accessible
+// (e.g. a private subreddit)
+func (e *RequestError) NotFound() bool {
+	switch e.StatusCode {
+	case http.StatusNotFound, http.StatusForbidden, http.StatusGone:
+		return true
+	}
+	return false
+}
+
+// classify returns the HTTP status of the error if any, and whether the error
+// is worth retrying
+func classify(err error) (int, bool) {
+	var rateErr *reddit.RateLimitError
+	if errors.As(err, &rateErr) {
+		return http.StatusTooManyRequests, true
+	}
+
+	var respErr *reddit.ErrorResponse
+	if errors.As(err, &respErr) && respErr.Response != nil {
+		status := respErr.Response.StatusCode
+		switch {
+		case status == http.StatusTooManyRequests, status == http.StatusRequestTimeout:
+			return status, true
+		case status >= 500:
+			return status, true
+		default:
+			return status, false
+		}
+	}
+
+	var jsonErr *reddit.JSONErrorResponse
+	if errors.As(err, &jsonErr) && jsonErr.Response != nil {
+		return jsonErr.Response.StatusCode, false
+	}
+
+	// timeout of one attempt (the parent context is checked by the caller)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return 0, true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return 0, netErr.Timeout() || netErr.Temporary()
+	}
+
+	return 0, false
+}
+
+// do calls `request` until it succeeds, using the rate limiter and following
+// the RetryPolicy of the bot. Each attempt has its own timeout. The error
+// returned is either the error of ctx or a *RequestError.
+func (bot Bot) do(ctx context.Context, request func(context.Context) (*reddit.Response, error)) error {
+	policy := bot.retry
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := bot.ratelimiter.Book(ctx)
+		if err != nil {
+			return err
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+		resp, err := request(reqCtx)
+		cancel()
+
+		// set ratelimiter with newer information, even for failed requests
+		if resp != nil {
+			bot.ratelimiter.Update(resp.Rate)
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		// cancelled by the caller
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		status, retryable := classify(err)
+		if !retryable || attempt >= policy.MaxAttempts {
+			return &RequestError{
+				Err:        err,
+				StatusCode: status,
+				Attempts:   attempt,
+				Retryable:  retryable,
+			}
+		}
+
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
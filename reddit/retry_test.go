@@ -0,0 +1,92 @@
+package reddit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vartanbeno/go-reddit/v2/reddit"
+)
+
+// getTestBot returns a bot calling `handler` instead of the reddit API
+func getTestBot(t *testing.T, handler http.HandlerFunc) *Bot {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := reddit.NewReadonlyClient(reddit.WithBaseURL(server.URL))
+	assert.NoError(t, err)
+
+	bot := newBot(client, readonlyBudget)
+	bot.retry = RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    5 * time.Millisecond,
+	}
+	return bot
+}
+
+const emptyListing = `{"kind": "Listing", "data": {"children": []}}`
+
+func TestRetryTemporaryError(t *testing.T) {
+	calls := 0
+	bot := getTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(emptyListing))
+	})
+
+	posts, err := bot.newPosts(context.Background(), "MechanicalKeyboards", "", "", 5)
+	assert.NoError(t, err)
+	assert.Empty(t, posts)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryExhausted(t *testing.T) {
+	calls := 0
+	bot := getTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	_, err := bot.newPosts(context.Background(), "MechanicalKeyboards", "", "", 5)
+
+	var reqErr *RequestError
+	assert.True(t, errors.As(err, &reqErr))
+	assert.True(t, reqErr.Temporary())
+	assert.Equal(t, http.StatusServiceUnavailable, reqErr.StatusCode)
+	assert.Equal(t, 3, reqErr.Attempts)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryPermanentError(t *testing.T) {
+	calls := 0
+	bot := getTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	_, err := bot.newPosts(context.Background(), "private_sub", "", "", 5)
+
+	var reqErr *RequestError
+	assert.True(t, errors.As(err, &reqErr))
+	assert.False(t, reqErr.Temporary())
+	assert.True(t, reqErr.NotFound())
+	assert.Equal(t, 1, reqErr.Attempts)
+	assert.Equal(t, 1, calls)
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second} {
+		delay := policy.backoff(attempt + 1)
+		assert.True(t, delay >= max/2 && delay < max, "attempt %d: %v", attempt+1, delay)
+	}
+}
@@ -0,0 +1,148 @@
+package reddit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/vartanbeno/go-reddit/v2/reddit"
+)
+
+// Reasons for which a subreddit can't be followed
+const (
+	SubredditInvalid     = "invalid"
+	SubredditNotFound    = "not found"
+	SubredditPrivate     = "private"
+	SubredditBanned      = "banned"
+	SubredditQuarantined = "quarantined"
+)
+
+// SubredditError tells why a subreddit can't be followed by a Feed
+type SubredditError struct {
+	Name string
+	// Reason is one of SubredditInvalid, SubredditNotFound, SubredditPrivate,
+	// SubredditBanned or SubredditQuarantined
+	Reason string
+}
+
+func (e *SubredditError) Error() string {
+	switch e.Reason {
+	case SubredditInvalid:
+		return fmt.Sprintf("%q is not a valid subreddit name", e.Name)
+	case SubredditNotFound:
+		return fmt.Sprintf("r/%s does not exist", e.Name)
+	default:
+		return fmt.Sprintf("r/%s is %s", e.Name, e.Reason)
+	}
+}
+
+// subredditNameRx matches the valid names of subreddits
+var subredditNameRx = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_]{1,20}$`)
+
+// readableTypes are the types of subreddits whose posts can be read by anyone
+var readableTypes = map[string]bool{
+	"public":          true,
+	"restricted":      true,
+	"archived":        true,
+	"gold_restricted": true,
+}
+
+// TrimSubredditName removes the "r/" or "/r/" prefix of a subreddit name
+func TrimSubredditName(name string) string {
+	name = strings.TrimSpace(name)
+	name = strings.TrimPrefix(name, "/")
+	if strings.HasPrefix(strings.ToLower(name), "r/") {
+		name = name[2:]
+	}
+	return strings.TrimSuffix(name, "/")
+}
+
+// reasonKey is the context key of the *string receiving the reason of a
+// refused request, see captureReason
+type reasonKey struct{}
+
+// captureReason is the request completion callback of the clients: reddit
+// explains why a subreddit is unavailable in the "reason" field of the error,
+// which go-reddit drops. It is stored in the context of the request if it
+// asks for it.
+func captureReason(_ *http.Request, resp *http.Response) {
+	// the request given to the callback is the one without context
+	reason, ok := resp.Request.Context().Value(reasonKey{}).(*string)
+	if !ok || resp.StatusCode < 400 {
+		return
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	// reset response body for go-reddit
+	resp.Body = ioutil.NopCloser(bytes.NewBuffer(data))
+	if err != nil {
+		return
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if json.Unmarshal(data, &body) == nil {
+		*reason = body.Reason
+	}
+}
+
+// CheckSubreddit verifies that the posts of the subreddit `name` can be read
+// and returns its name as spelled by reddit. The error is a *SubredditError
+// if the subreddit is private, banned, missing, etc.
+func (bot *Bot) CheckSubreddit(name string) (string, error) {
+	return bot.CheckSubredditContext(context.Background(), name)
+}
+
+// CheckSubredditContext is like CheckSubreddit with a context.
+func (bot *Bot) CheckSubredditContext(ctx context.Context, name string) (string, error) {
+	name = TrimSubredditName(name)
+	if !subredditNameRx.MatchString(name) {
+		return "", &SubredditError{name, SubredditInvalid}
+	}
+
+	var reason string
+	ctx = context.WithValue(ctx, reasonKey{}, &reason)
+
+	var about *reddit.Subreddit
+	err := bot.do(ctx, func(ctx context.Context) (*reddit.Response, error) {
+		var resp *reddit.Response
+		var err error
+		about, resp, err = bot.client.Subreddit.Get(ctx, name)
+		return resp, err
+	})
+
+	if reqErr, ok := err.(*RequestError); ok && reqErr.NotFound() {
+		switch reason {
+		case "private":
+			return "", &SubredditError{name, SubredditPrivate}
+		case "banned":
+			return "", &SubredditError{name, SubredditBanned}
+		case "quarantined":
+			return "", &SubredditError{name, SubredditQuarantined}
+		}
+		if reqErr.StatusCode == http.StatusForbidden {
+			return "", &SubredditError{name, SubredditPrivate}
+		}
+		return "", &SubredditError{name, SubredditNotFound}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// reddit redirects the unknown subreddits to a search
+	if about == nil || len(about.Name) == 0 {
+		return "", &SubredditError{name, SubredditNotFound}
+	}
+	if !readableTypes[about.Type] {
+		return "", &SubredditError{about.Name, SubredditPrivate}
+	}
+
+	return about.Name, nil
+}
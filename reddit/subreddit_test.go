@@ -0,0 +1,60 @@
+package reddit
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSubreddit(t *testing.T) {
+	bot := getTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		name := strings.Split(r.URL.Path, "/")[2]
+		switch strings.ToLower(name) {
+		case "mechanicalkeyboards":
+			fmt.Fprint(w, `{"kind": "t5", "data": {"display_name": "MechanicalKeyboards", "subreddit_type": "public"}}`)
+		case "secret":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"reason": "private", "message": "Forbidden", "error": 403}`)
+		case "gone":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"reason": "banned", "message": "Not Found", "error": 404}`)
+		case "members":
+			fmt.Fprint(w, `{"kind": "t5", "data": {"display_name": "members", "subreddit_type": "employees_only"}}`)
+		case "mechanicalkeybaords":
+			// reddit redirects to a search of the subreddits
+			fmt.Fprint(w, emptyListing)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found", "error": 404}`)
+		}
+	})
+
+	name, err := bot.CheckSubreddit("r/mechanicalkeyboards")
+	assert.Nil(t, err)
+	assert.Equal(t, "MechanicalKeyboards", name)
+
+	cases := map[string]string{
+		"secret":              SubredditPrivate,
+		"gone":                SubredditBanned,
+		"members":             SubredditPrivate,
+		"MechanicalKeybaords": SubredditNotFound,
+		"missing":             SubredditNotFound,
+		"key boards":          SubredditInvalid,
+		"x":                   SubredditInvalid,
+	}
+	for name, reason := range cases {
+		_, err := bot.CheckSubreddit(name)
+		if assert.IsType(t, &SubredditError{}, err, name) {
+			assert.Equal(t, reason, err.(*SubredditError).Reason, name)
+		}
+	}
+}
+
+func TestTrimSubredditName(t *testing.T) {
+	for _, name := range []string{"mk", "r/mk", "/r/mk/", " R/mk "} {
+		assert.Equal(t, "mk", TrimSubredditName(name))
+	}
+}
@@ -16,6 +16,15 @@ func minOfAnchor(points []Position) time.Time {
 	return points[min].Created.Time
 }
 
+// rawPosts returns the posts as decoded by github.com/vartanbeno/go-reddit
+func rawPosts(posts []*Post) []*reddit.Post {
+	raw := make([]*reddit.Post, len(posts))
+	for i, post := range posts {
+		raw[i] = &post.Post
+	}
+	return raw
+}
+
 // doubly linked list node
 type listItem struct {
 	value *reddit.Post
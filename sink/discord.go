@@ -0,0 +1,119 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// discordColor is the color of the embeds (orange, as reddit)
+const discordColor = 0xff4500
+
+// the limits of the fields of an embed
+const (
+	maxEmbedTitle      = 256
+	maxEmbedFieldValue = 1024
+)
+
+// Discord sends the notifications to a Discord channel through a webhook
+// (see https://discord.com/developers/docs/resources/webhook), as an embed
+// with the title, author and link of the post
+type Discord struct {
+	// URL of the webhook, as given by Discord
+	URL string `json:"url"`
+	// Client sends the requests, a client with a 30s timeout is used if nil
+	Client *http.Client `json:"-"`
+}
+
+type discordMessage struct {
+	Embeds []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title     string         `json:"title"`
+	URL       string         `json:"url"`
+	Color     int            `json:"color"`
+	Author    discordAuthor  `json:"author"`
+	Fields    []discordField `json:"fields,omitempty"`
+	Footer    discordFooter  `json:"footer"`
+	Timestamp string         `json:"timestamp,omitempty"`
+}
+
+type discordAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type discordFooter struct {
+	Text string `json:"text"`
+}
+
+func (d *Discord) check() error {
+	return checkURL(d.URL)
+}
+
+// embed returns the embed of a notification
+func (d *Discord) embed(n *Notification) discordEmbed {
+	embed := discordEmbed{
+		Title:  truncate(n.Title, maxEmbedTitle),
+		URL:    n.URL(),
+		Color:  discordColor,
+		Author: discordAuthor{Name: "u/" + n.Author, URL: n.AuthorURL()},
+		Footer: discordFooter{Text: "r/" + n.Subreddit},
+	}
+	if !n.Created.IsZero() {
+		embed.Timestamp = n.Created.UTC().Format(time.RFC3339)
+	}
+
+	if !n.Deadline.IsZero() {
+		embed.Fields = append(embed.Fields, discordField{
+			// rendered in the time zone of each reader
+			Name: "Ends", Value: fmt.Sprintf("<t:%d:F>", n.Deadline.Unix()), Inline: true,
+		})
+	}
+	if n.Eligibility.Known() {
+		embed.Fields = append(embed.Fields, discordField{
+			Name: "Open to", Value: n.Eligibility.String(), Inline: true,
+		})
+	}
+	embed.Fields = append(embed.Fields, discordField{
+		Name: "Matched", Value: truncate(n.Classification.String(), maxEmbedFieldValue),
+	})
+
+	return embed
+}
+
+// Send posts the notification to the webhook
+func (d *Discord) Send(ctx context.Context, n *Notification) error {
+	data, err := json.Marshal(discordMessage{Embeds: []discordEmbed{d.embed(n)}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	return do(d.Client, req)
+}
+
+// truncate cuts `s` to at most `max` runes, ending with an ellipsis if cut
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,53 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscord(t *testing.T) {
+	var received discordMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink, err := New(Config{Type: TypeDiscord, Params: json.RawMessage(`{"url": "` + server.URL + `"}`)})
+	assert.Nil(t, err)
+	assert.Nil(t, sink.Send(context.Background(), testNotification()))
+
+	assert.Len(t, received.Embeds, 1)
+	embed := received.Embeds[0]
+	assert.Equal(t, "GMK Olivia [Giveaway]", embed.Title)
+	assert.Equal(t, "https://old.reddit.com/r/MechanicalKeyboards/comments/abc/gmk_olivia_giveaway/", embed.URL)
+	assert.Equal(t, "u/someone", embed.Author.Name)
+	assert.Equal(t, "https://old.reddit.com/user/someone", embed.Author.URL)
+	assert.Equal(t, "r/MechanicalKeyboards", embed.Footer.Text)
+	assert.Equal(t, "2021-06-01T12:00:00Z", embed.Timestamp)
+	assert.Len(t, embed.Fields, 3)
+	assert.Equal(t, "<t:1622826000:F>", embed.Fields[0].Value)
+	assert.Equal(t, "us", embed.Fields[1].Value)
+}
+
+func TestDiscordError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"message": "You are being rate limited."}`))
+	}))
+	defer server.Close()
+
+	err := (&Discord{URL: server.URL}).Send(context.Background(), testNotification())
+	assert.IsType(t, &StatusError{}, err)
+	assert.Equal(t, http.StatusTooManyRequests, err.(*StatusError).StatusCode)
+	assert.True(t, err.(*StatusError).Temporary())
+	assert.True(t, strings.Contains(err.Error(), "rate limited"))
+}
@@ -0,0 +1,282 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the default ports of the SMTP servers
+const (
+	submissionPort = 587
+	smtpsPort      = 465
+)
+
+// Email sends the notifications by email through an SMTP server, one email
+// per giveaway or, with Digest, one email per update of the feed listing its
+// new giveaways
+type Email struct {
+	Host string `json:"host"`
+	// Port of the server, 587 by default (465 with TLS)
+	Port     int      `json:"port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+	// TLS connects with TLS (port 465) instead of using STARTTLS
+	TLS bool `json:"tls,omitempty"`
+	// Insecure allows servers not supporting STARTTLS
+	Insecure bool `json:"insecure,omitempty"`
+	// Digest groups the notifications of an update in one email, see Flush
+	Digest bool `json:"digest,omitempty"`
+	// UnsubscribeURL is the link given to stop the emails (and its
+	// List-Unsubscribe header), if any
+	UnsubscribeURL string `json:"unsubscribe_url,omitempty"`
+	// Unsubscribe explains how to stop the emails (e.g. the command removing
+	// the sink), it is set by the bot
+	Unsubscribe string `json:"-"`
+
+	pending []*Notification
+}
+
+func (e *Email) check() error {
+	if len(e.Host) == 0 {
+		return fmt.Errorf("host is required")
+	}
+	if _, err := mail.ParseAddress(e.From); err != nil {
+		return fmt.Errorf("invalid from address %q: %v", e.From, err)
+	}
+	if len(e.To) == 0 {
+		return fmt.Errorf("to is required")
+	}
+	for _, to := range e.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid to address %q: %v", to, err)
+		}
+	}
+	if len(e.UnsubscribeURL) > 0 {
+		return checkURL(e.UnsubscribeURL)
+	}
+	return nil
+}
+
+// port returns the port of the server
+func (e *Email) port() int {
+	switch {
+	case e.Port != 0:
+		return e.Port
+	case e.TLS:
+		return smtpsPort
+	default:
+		return submissionPort
+	}
+}
+
+// Send emails the notification, or keeps it for Flush with Digest
+func (e *Email) Send(ctx context.Context, n *Notification) error {
+	if e.Digest {
+		e.pending = append(e.pending, n)
+		return nil
+	}
+	return e.send(ctx, n.Title, []*Notification{n})
+}
+
+// Flush emails the digest of the notifications kept since the last call
+func (e *Email) Flush(ctx context.Context) error {
+	if len(e.pending) == 0 {
+		return nil
+	}
+
+	subject := "1 new giveaway: " + e.pending[0].Title
+	if len(e.pending) > 1 {
+		subject = fmt.Sprintf("%d new giveaways", len(e.pending))
+	}
+
+	err := e.send(ctx, subject, e.pending)
+	e.pending = nil
+	return err
+}
+
+var emailTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body>
+{{range .Notifications}}<div>
+<h3><a href="{{.URL}}">{{.Title}}</a></h3>
+<p>by <a href="{{.AuthorURL}}">u/{{.Author}}</a> in r/{{.Subreddit}}, posted {{.Created.Local.Format "Mon Jan 2 15:04"}}</p>
+<ul>
+{{range .Details}}<li>{{.}}</li>
+{{end}}</ul>
+</div>
+{{end}}<hr>
+<p><small>{{if .UnsubscribeURL}}<a href="{{.UnsubscribeURL}}">Unsubscribe</a>{{if .Unsubscribe}} or {{end}}{{end}}{{.Unsubscribe}}</small></p>
+</body>
+</html>
+`))
+
+// text returns the plain text body of an email listing `notifications`
+func (e *Email) text(notifications []*Notification) string {
+	var body strings.Builder
+	for _, n := range notifications {
+		body.WriteString(n.Title + "\n")
+		fmt.Fprintf(&body, "by u/%s in r/%s, posted %s\n", n.Author, n.Subreddit, n.Created.Local().Format("Mon Jan 2 15:04"))
+		body.WriteString(n.URL() + "\n")
+		for _, line := range n.Details() {
+			body.WriteString(line + "\n")
+		}
+		body.WriteString("\n")
+	}
+
+	body.WriteString("-- \n")
+	if len(e.UnsubscribeURL) > 0 {
+		body.WriteString("Unsubscribe: " + e.UnsubscribeURL + "\n")
+	}
+	if len(e.Unsubscribe) > 0 {
+		body.WriteString(e.Unsubscribe + "\n")
+	}
+	return body.String()
+}
+
+// message returns the email listing `notifications`, with a plain text and an
+// HTML body
+func (e *Email) message(subject string, notifications []*Notification, now time.Time) ([]byte, error) {
+	var html bytes.Buffer
+	err := emailTemplate.Execute(&html, struct {
+		*Email
+		Notifications []*Notification
+	}{e, notifications})
+	if err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	parts := multipart.NewWriter(&msg)
+
+	header := []string{
+		"From: " + e.From,
+		"To: " + strings.Join(e.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + now.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + parts.Boundary(),
+	}
+	if len(e.UnsubscribeURL) > 0 {
+		header = append(header, "List-Unsubscribe: <"+e.UnsubscribeURL+">")
+	}
+	msg.WriteString(strings.Join(header, "\r\n") + "\r\n\r\n")
+
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", e.text(notifications)},
+		{"text/html; charset=utf-8", html.String()},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		_, err = qp.Write([]byte(part.body))
+		if err != nil {
+			return nil, err
+		}
+		err = qp.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = parts.Close()
+	if err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
+
+// send emails the notifications through the SMTP server
+func (e *Email) send(ctx context.Context, subject string, notifications []*Notification) error {
+	msg, err := e.message(subject, notifications, time.Now())
+	if err != nil {
+		return err
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(e.Host, strconv.Itoa(e.port())))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if e.TLS {
+		conn = tls.Client(conn, &tls.Config{ServerName: e.Host})
+	}
+
+	client, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if !e.TLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			err = client.StartTLS(&tls.Config{ServerName: e.Host})
+			if err != nil {
+				return err
+			}
+		} else if !e.Insecure {
+			return fmt.Errorf("%s doesn't support STARTTLS", e.Host)
+		}
+	}
+
+	if len(e.Username) > 0 {
+		err = client.Auth(smtp.PlainAuth("", e.Username, e.Password, e.Host))
+		if err != nil {
+			return err
+		}
+	}
+
+	from, _ := mail.ParseAddress(e.From)
+	err = client.Mail(from.Address)
+	if err != nil {
+		return err
+	}
+	for _, to := range e.To {
+		address, _ := mail.ParseAddress(to)
+		err = client.Rcpt(address.Address)
+		if err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,187 @@
+package sink
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// smtpServer is a local stand-in for an SMTP server without STARTTLS, it
+// records the credentials and the messages it receives
+type smtpServer struct {
+	listener    net.Listener
+	mutex       sync.Mutex
+	credentials []string
+	messages    []string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	server := &smtpServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.serve(textproto.NewConn(conn))
+		}
+	}()
+	return server
+}
+
+func (s *smtpServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpServer) serve(conn *textproto.Conn) {
+	defer conn.Close()
+
+	conn.PrintfLine("220 localhost ready")
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch command {
+		case "EHLO":
+			conn.PrintfLine("250-localhost\r\n250 AUTH PLAIN")
+		case "AUTH":
+			credentials, _ := base64.StdEncoding.DecodeString(strings.Fields(line)[2])
+			s.mutex.Lock()
+			s.credentials = append(s.credentials, string(credentials))
+			s.mutex.Unlock()
+			conn.PrintfLine("235 authenticated")
+		case "DATA":
+			conn.PrintfLine("354 go ahead")
+			data, _ := conn.ReadDotBytes()
+			s.mutex.Lock()
+			s.messages = append(s.messages, string(data))
+			s.mutex.Unlock()
+			conn.PrintfLine("250 queued")
+		case "QUIT":
+			conn.PrintfLine("221 bye")
+			return
+		default:
+			conn.PrintfLine("250 OK")
+		}
+	}
+}
+
+// parts returns the bodies of a multipart email, by content type
+func parts(t *testing.T, message string) (*mail.Message, map[string]string) {
+	msg, err := mail.ReadMessage(strings.NewReader(message))
+	assert.Nil(t, err)
+
+	boundary := strings.SplitN(msg.Header.Get("Content-Type"), "boundary=", 2)[1]
+	reader := multipart.NewReader(msg.Body, boundary)
+
+	bodies := make(map[string]string)
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := ioutil.ReadAll(part)
+		bodies[strings.SplitN(part.Header.Get("Content-Type"), ";", 2)[0]] = string(body)
+	}
+	return msg, bodies
+}
+
+func TestEmail(t *testing.T) {
+	server := newSMTPServer(t)
+	defer server.listener.Close()
+
+	s, err := New(Config{Type: TypeEmail, Params: []byte(`{
+		"host": "127.0.0.1", "port": ` + strconv.Itoa(server.port()) + `,
+		"username": "bot", "password": "secret", "insecure": true,
+		"from": "Giveaways <bot@example.com>", "to": ["someone@example.com"],
+		"unsubscribe_url": "https://example.com/unsubscribe"
+	}`)})
+	assert.Nil(t, err)
+	s.(*Email).Unsubscribe = "Send /sink remove 2 to the bot."
+
+	assert.Nil(t, s.Send(context.Background(), testNotification()))
+	assert.Equal(t, []string{"\x00bot\x00secret"}, server.credentials)
+	assert.Len(t, server.messages, 1)
+
+	msg, bodies := parts(t, server.messages[0])
+	assert.Equal(t, "GMK Olivia [Giveaway]", msg.Header.Get("Subject"))
+	assert.Equal(t, "<https://example.com/unsubscribe>", msg.Header.Get("List-Unsubscribe"))
+
+	text := bodies["text/plain"]
+	assert.Contains(t, text, "by u/someone in r/MechanicalKeyboards, posted")
+	assert.Contains(t, text, "https://old.reddit.com/r/MechanicalKeyboards/comments/abc/gmk_olivia_giveaway/")
+	assert.Contains(t, text, "Send /sink remove 2 to the bot.")
+
+	html := bodies["text/html"]
+	assert.Contains(t, html, `<a href="https://old.reddit.com/r/MechanicalKeyboards/comments/abc/gmk_olivia_giveaway/">GMK Olivia [Giveaway]</a>`)
+	assert.Contains(t, html, `<a href="https://example.com/unsubscribe">Unsubscribe</a>`)
+}
+
+func TestEmailDigest(t *testing.T) {
+	server := newSMTPServer(t)
+	defer server.listener.Close()
+
+	email := &Email{
+		Host: "127.0.0.1", Port: server.port(), Insecure: true, Digest: true,
+		From: "bot@example.com", To: []string{"someone@example.com"},
+	}
+	second := testNotification()
+	second.Title = "Artisan [GA]"
+
+	assert.Nil(t, email.Send(context.Background(), testNotification()))
+	assert.Nil(t, email.Send(context.Background(), second))
+	assert.Empty(t, server.messages)
+
+	assert.Nil(t, email.Flush(context.Background()))
+	assert.Len(t, server.messages, 1)
+	assert.Empty(t, server.credentials)
+
+	msg, bodies := parts(t, server.messages[0])
+	assert.Equal(t, "2 new giveaways", msg.Header.Get("Subject"))
+	assert.Contains(t, bodies["text/plain"], "GMK Olivia [Giveaway]")
+	assert.Contains(t, bodies["text/plain"], "Artisan [GA]")
+
+	// nothing left to send
+	assert.Nil(t, email.Flush(context.Background()))
+	assert.Len(t, server.messages, 1)
+}
+
+func TestEmailRequiresStartTLS(t *testing.T) {
+	server := newSMTPServer(t)
+	defer server.listener.Close()
+
+	email := &Email{
+		Host: "127.0.0.1", Port: server.port(),
+		From: "bot@example.com", To: []string{"someone@example.com"},
+	}
+	assert.NotNil(t, email.Send(context.Background(), testNotification()))
+	assert.Empty(t, server.messages)
+}
+
+func TestEmailCheck(t *testing.T) {
+	_, err := Parse(TypeEmail, `{"host": "smtp.example.com", "from": "bot@example.com", "to": ["someone@example.com"]}`)
+	assert.Nil(t, err)
+	_, err = Parse(TypeEmail, `{"host": "smtp.example.com", "from": "bot@example.com"}`)
+	assert.NotNil(t, err)
+	_, err = Parse(TypeEmail, `{"host": "smtp.example.com", "from": "bot", "to": ["someone@example.com"]}`)
+	assert.NotNil(t, err)
+
+	config := Config{Type: TypeEmail, Params: []byte(`{"host": "smtp.example.com", "password": "secret"}`)}
+	assert.Equal(t, "email (smtp.example.com)", config.String())
+}
@@ -0,0 +1,77 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// matrixTxn numbers the messages sent by the process, see Matrix.Send
+var matrixTxn uint64
+
+// Matrix posts the notifications in a Matrix room through the client-server
+// API (see https://spec.matrix.org/latest/client-server-api/), with an HTML
+// body. The account of the access token must have joined the room.
+type Matrix struct {
+	// Homeserver is the base URL of the server, e.g. "https://matrix.org"
+	Homeserver  string `json:"homeserver"`
+	AccessToken string `json:"access_token"`
+	// RoomID is the ID of the room, e.g. "!abc123:matrix.org"
+	RoomID string `json:"room_id"`
+	// Client sends the requests, a client with a 30s timeout is used if nil
+	Client *http.Client `json:"-"`
+}
+
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+}
+
+func (m *Matrix) check() error {
+	if len(m.AccessToken) == 0 {
+		return fmt.Errorf("access_token is required")
+	}
+	if !strings.HasPrefix(m.RoomID, "!") || !strings.Contains(m.RoomID, ":") {
+		return fmt.Errorf("%q is not a room ID (e.g. !abc123:matrix.org)", m.RoomID)
+	}
+	return checkURL(m.Homeserver)
+}
+
+// Send posts the notification in the room as an m.text message
+func (m *Matrix) Send(ctx context.Context, n *Notification) error {
+	data, err := json.Marshal(matrixMessage{
+		MsgType:       "m.text",
+		Body:          n.Text(),
+		Format:        "org.matrix.custom.html",
+		FormattedBody: n.HTML(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// the homeserver ignores the messages reusing the transaction ID of a
+	// previous message of the access token
+	txn := fmt.Sprintf("%d.%d", time.Now().UnixNano(), atomic.AddUint64(&matrixTxn, 1))
+	endpoint := fmt.Sprintf(
+		"%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		strings.TrimRight(m.Homeserver, "/"), url.PathEscape(m.RoomID), txn,
+	)
+
+	req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.AccessToken)
+
+	return do(m.Client, req)
+}
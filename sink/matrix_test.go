@@ -0,0 +1,90 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newHomeserver returns a local stand-in for a Matrix homeserver accepting the
+// messages of `token` in the room !room:localhost
+func newHomeserver(t *testing.T, token string, received *[]matrixMessage) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errcode": "M_UNKNOWN_TOKEN", "error": "Unrecognised access token"}`))
+			return
+		}
+
+		prefix := "/_matrix/client/v3/rooms/!room:localhost/send/m.room.message/"
+		if r.Method != http.MethodPut || !strings.HasPrefix(r.URL.Path, prefix) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var message matrixMessage
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&message))
+		*received = append(*received, message)
+		w.Write([]byte(`{"event_id": "$event"}`))
+	}))
+}
+
+func TestMatrix(t *testing.T) {
+	var received []matrixMessage
+	server := newHomeserver(t, "token", &received)
+	defer server.Close()
+
+	config, err := Parse(TypeMatrix, `{"homeserver": "`+server.URL+`/", "access_token": "token", "room_id": "!room:localhost"}`)
+	assert.Nil(t, err)
+	sink, err := New(config)
+	assert.Nil(t, err)
+
+	n := testNotification()
+	assert.Nil(t, sink.Send(context.Background(), n))
+	assert.Nil(t, sink.Send(context.Background(), n))
+	assert.Len(t, received, 2)
+
+	message := received[0]
+	assert.Equal(t, "m.text", message.MsgType)
+	assert.Equal(t, n.Text(), message.Body)
+	assert.Equal(t, "org.matrix.custom.html", message.Format)
+	assert.True(t, strings.HasPrefix(message.FormattedBody,
+		`<a href="https://old.reddit.com/r/MechanicalKeyboards/comments/abc/gmk_olivia_giveaway/">GMK Olivia [Giveaway]</a>`+
+			` by <a href="https://old.reddit.com/user/someone">u/someone</a><br>`,
+	))
+	assert.Contains(t, message.FormattedBody, "<br>Open to us<br>Matched: ")
+}
+
+func TestMatrixError(t *testing.T) {
+	var received []matrixMessage
+	server := newHomeserver(t, "token", &received)
+	defer server.Close()
+
+	matrix := &Matrix{Homeserver: server.URL, AccessToken: "wrong", RoomID: "!room:localhost"}
+	err := matrix.Send(context.Background(), testNotification())
+	assert.IsType(t, &StatusError{}, err)
+	assert.Contains(t, err.Error(), "M_UNKNOWN_TOKEN")
+	assert.Empty(t, received)
+}
+
+func TestMatrixCheck(t *testing.T) {
+	_, err := Parse(TypeMatrix, `{"homeserver": "https://matrix.org", "access_token": "token", "room_id": "#room:matrix.org"}`)
+	assert.NotNil(t, err)
+	_, err = Parse(TypeMatrix, `{"homeserver": "https://matrix.org", "room_id": "!room:matrix.org"}`)
+	assert.NotNil(t, err)
+
+	config, err := Parse(TypeMatrix, `{"homeserver": "https://matrix.org", "access_token": "token", "room_id": "!room:matrix.org"}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "matrix (matrix.org)", config.String())
+}
+
+func TestNotificationHTML(t *testing.T) {
+	n := testNotification()
+	n.Title = "<b>Giveaway</b> & more"
+	assert.Contains(t, n.HTML(), ">&lt;b&gt;Giveaway&lt;/b&gt; &amp; more</a>")
+}
@@ -0,0 +1,109 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// AllowPrivateNetworks lets the sinks connect to loopback, private and
+// link-local addresses. The sinks are configured by the users of the bot: by
+// default they can't reach the services of the network of the bot, such as
+// the metadata service of a cloud provider (169.254.169.254). It must be set
+// before any sink is used.
+var AllowPrivateNetworks = false
+
+// DestinationError is returned when a sink would connect to an address which
+// isn't allowed, see AllowPrivateNetworks
+type DestinationError struct {
+	Address string
+}
+
+func (e *DestinationError) Error() string {
+	return fmt.Sprintf("%s is not a public address", e.Address)
+}
+
+// privateNetworks are the ranges which aren't reachable from the internet, on
+// top of the ones of the methods of net.IP
+var privateNetworks = parseNetworks(
+	"0.0.0.0/8",      // this network
+	"10.0.0.0/8",     // RFC 1918
+	"100.64.0.0/10",  // shared address space (carrier-grade NAT)
+	"172.16.0.0/12",  // RFC 1918
+	"192.0.0.0/24",   // IETF protocol assignments
+	"192.168.0.0/16", // RFC 1918
+	"198.18.0.0/15",  // benchmarking
+	"fc00::/7",       // unique local addresses
+)
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// publicAddress returns true if `ip` is a unicast address reachable from the
+// internet
+func publicAddress(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkDestination is the Control function of the dialer of the sinks: it is
+// called with the resolved address, so that a name can't point to a private
+// address after being checked.
+func checkDestination(network, address string, _ syscall.RawConn) error {
+	if AllowPrivateNetworks {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicAddress(ip) {
+		return &DestinationError{Address: host}
+	}
+	return nil
+}
+
+// dialer connects the sinks to their services
+var dialer = &net.Dialer{Timeout: requestTimeout, Control: checkDestination}
+
+// newDefaultClient returns the client of the sinks without Client, connected
+// through dialer. A proxy of the environment must be public as well.
+func newDefaultClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: requestTimeout, Transport: transport}
+}
+
+// PublicError describes an error of a sink to the users of the bot: the
+// responses of the services are left out.
+func PublicError(err error) string {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return fmt.Sprintf("unexpected status %d", statusErr.StatusCode)
+	}
+	var destErr *DestinationError
+	if errors.As(err, &destErr) {
+		return destErr.Error()
+	}
+	return err.Error()
+}
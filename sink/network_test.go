@@ -0,0 +1,62 @@
+package sink
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// the local stand-ins of the services listen on the loopback
+	AllowPrivateNetworks = true
+	os.Exit(m.Run())
+}
+
+func TestPublicAddress(t *testing.T) {
+	for _, ip := range []string{"127.0.0.1", "::1", "169.254.169.254", "10.1.2.3", "172.20.0.1", "192.168.1.1", "100.64.0.1", "0.0.0.0", "fd00::1", "fe80::1", "::ffff:127.0.0.1"} {
+		assert.False(t, publicAddress(net.ParseIP(ip)), ip)
+	}
+	for _, ip := range []string{"1.1.1.1", "172.32.0.1", "2606:4700:4700::1111"} {
+		assert.True(t, publicAddress(net.ParseIP(ip)), ip)
+	}
+}
+
+func TestPrivateDestination(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal secret"))
+	}))
+	defer server.Close()
+
+	AllowPrivateNetworks = false
+	defer func() { AllowPrivateNetworks = true }()
+
+	discord := &Discord{URL: server.URL}
+	err := discord.Send(context.Background(), testNotification())
+	assert.Equal(t, "127.0.0.1 is not a public address", PublicError(err))
+
+	email := &Email{Host: "127.0.0.1", Port: 25, From: "bot@example.com", To: []string{"me@example.com"}}
+	err = email.Send(context.Background(), testNotification())
+	assert.Equal(t, "127.0.0.1 is not a public address", PublicError(err))
+	assert.Equal(t, 0, requests)
+
+	// a refused webhook isn't retried
+	dead := &letters{}
+	webhook := &Webhook{URL: server.URL, Secret: "secret", DeadLetters: dead}
+	err = webhook.Send(context.Background(), testNotification())
+	assert.Equal(t, "127.0.0.1 is not a public address", PublicError(err))
+	assert.Equal(t, 1, dead.records[0].Attempts)
+
+	// the responses of the services aren't shown
+	AllowPrivateNetworks = true
+	err = discord.Send(context.Background(), testNotification())
+	assert.Contains(t, err.Error(), "internal secret")
+	assert.Equal(t, "unexpected status 500", PublicError(err))
+}
@@ -0,0 +1,253 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/duration"
+)
+
+// DefaultSoon is the default delay before the deadline under which the push
+// notifications of a giveaway have SoonPriority
+const DefaultSoon = 24 * time.Hour
+
+// the priorities of ntfy (1 to 5) and Gotify (0 to 10)
+const (
+	ntfyDefaultPriority   = 3
+	ntfyHighPriority      = 4
+	ntfyMinPriority       = 1
+	ntfyMaxPriority       = 5
+	gotifyDefaultPriority = 5
+	gotifyHighPriority    = 8
+	gotifyMinPriority     = 0
+	gotifyMaxPriority     = 10
+)
+
+// Duration is a time.Duration written as a string in JSON (e.g. "6h", "1d")
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := duration.Parse(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Priorities selects the priority of the push notifications: SoonPriority
+// for the giveaways ending within Soon, Priority for the others. The
+// priorities left out select the default and the high priority of the
+// service, a zero Soon selects DefaultSoon.
+type Priorities struct {
+	Priority     *int     `json:"priority,omitempty"`
+	SoonPriority *int     `json:"soon_priority,omitempty"`
+	Soon         Duration `json:"soon,omitempty"`
+}
+
+// check returns an error if a priority isn't in [min, max] or Soon is
+// negative
+func (p Priorities) check(min, max int) error {
+	for _, priority := range []*int{p.Priority, p.SoonPriority} {
+		if priority != nil && (*priority < min || *priority > max) {
+			return fmt.Errorf("priorities must be between %d and %d", min, max)
+		}
+	}
+	if p.Soon < 0 {
+		return fmt.Errorf("soon must not be negative")
+	}
+	return nil
+}
+
+// priority returns the priority of `n` at `now`, given the default and high
+// priorities of the service
+func (p Priorities) priority(n *Notification, now time.Time, normal, high int) int {
+	soon := time.Duration(p.Soon)
+	if soon == 0 {
+		soon = DefaultSoon
+	}
+
+	if !n.Deadline.IsZero() && n.Deadline.After(now) && n.Deadline.Sub(now) <= soon {
+		if p.SoonPriority != nil {
+			return *p.SoonPriority
+		}
+		return high
+	}
+	if p.Priority != nil {
+		return *p.Priority
+	}
+	return normal
+}
+
+// tags returns the names of the rules of the classifier which matched the
+// post positively
+func tags(n *Notification) []string {
+	var tags []string
+	found := make(map[string]bool)
+	for _, match := range n.Classification.Matches {
+		if match.Weight > 0 && !found[match.Rule] {
+			found[match.Rule] = true
+			tags = append(tags, match.Rule)
+		}
+	}
+	return tags
+}
+
+// pushMessage is the body of the push notifications
+func pushMessage(n *Notification) string {
+	lines := []string{fmt.Sprintf("by u/%s in r/%s", n.Author, n.Subreddit)}
+	return strings.Join(append(lines, n.Details()...), "\n")
+}
+
+// Ntfy publishes the notifications to an ntfy topic (see https://ntfy.sh)
+type Ntfy struct {
+	// URL of the topic, e.g. "https://ntfy.sh/mk-giveaways"
+	URL string `json:"url"`
+	// Token is the access token of protected topics, if any
+	Token string `json:"token,omitempty"`
+	Priorities
+	// Client sends the requests, a client with a 30s timeout is used if nil
+	Client *http.Client `json:"-"`
+}
+
+type ntfyMessage struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority"`
+	Tags     []string `json:"tags,omitempty"`
+	Click    string   `json:"click"`
+}
+
+func (s *Ntfy) check() error {
+	err := checkURL(s.URL)
+	if err != nil {
+		return err
+	}
+	if _, topic := s.topic(); len(topic) == 0 {
+		return fmt.Errorf("%q has no topic", s.URL)
+	}
+	return s.Priorities.check(ntfyMinPriority, ntfyMaxPriority)
+}
+
+// topic splits the URL of the topic in the URL of the server and the topic
+func (s *Ntfy) topic() (string, string) {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return "", ""
+	}
+
+	topic := path.Base(u.Path)
+	if topic == "/" || topic == "." {
+		return "", ""
+	}
+	u.Path = path.Dir(u.Path)
+	return strings.TrimRight(u.String(), "/"), topic
+}
+
+// Send publishes the notification as JSON to the server of the topic
+func (s *Ntfy) Send(ctx context.Context, n *Notification) error {
+	server, topic := s.topic()
+	data, err := json.Marshal(ntfyMessage{
+		Topic:    topic,
+		Title:    n.Title,
+		Message:  pushMessage(n),
+		Priority: s.priority(n, time.Now(), ntfyDefaultPriority, ntfyHighPriority),
+		Tags:     tags(n),
+		Click:    n.URL(),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, server+"/", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if len(s.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	return do(s.Client, req)
+}
+
+// Gotify sends the notifications to a Gotify application (see
+// https://gotify.net). Gotify has no tags, they are listed in the message.
+type Gotify struct {
+	// URL of the server, e.g. "https://gotify.example.com"
+	URL string `json:"url"`
+	// Token is the token of the application
+	Token string `json:"token"`
+	Priorities
+	// Client sends the requests, a client with a 30s timeout is used if nil
+	Client *http.Client `json:"-"`
+}
+
+type gotifyMessage struct {
+	Title    string                 `json:"title"`
+	Message  string                 `json:"message"`
+	Priority int                    `json:"priority"`
+	Extras   map[string]interface{} `json:"extras"`
+}
+
+func (s *Gotify) check() error {
+	if len(s.Token) == 0 {
+		return fmt.Errorf("token is required")
+	}
+	err := checkURL(s.URL)
+	if err != nil {
+		return err
+	}
+	return s.Priorities.check(gotifyMinPriority, gotifyMaxPriority)
+}
+
+// Send creates a message of the application
+func (s *Gotify) Send(ctx context.Context, n *Notification) error {
+	message := pushMessage(n)
+	if tags := tags(n); len(tags) > 0 {
+		message += "\nTags: " + strings.Join(tags, ", ")
+	}
+
+	data, err := json.Marshal(gotifyMessage{
+		Title:    n.Title,
+		Message:  message,
+		Priority: s.priority(n, time.Now(), gotifyDefaultPriority, gotifyHighPriority),
+		Extras: map[string]interface{}{
+			"client::notification": map[string]interface{}{
+				"click": map[string]string{"url": n.URL()},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(s.URL, "/")+"/message", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", s.Token)
+
+	return do(s.Client, req)
+}
@@ -0,0 +1,132 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newPushServer returns a local stand-in for an ntfy or Gotify server which
+// decodes the messages posted to `path` in `received`
+func newPushServer(t *testing.T, path string, received interface{}, headers *http.Header) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != path {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		*headers = r.Header
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(received))
+		w.Write([]byte(`{}`))
+	}))
+}
+
+func TestNtfy(t *testing.T) {
+	var received ntfyMessage
+	var headers http.Header
+	server := newPushServer(t, "/", &received, &headers)
+	defer server.Close()
+
+	config, err := Parse(TypeNtfy, server.URL+"/mk-giveaways")
+	assert.Nil(t, err)
+	sink, err := New(config)
+	assert.Nil(t, err)
+
+	n := testNotification()
+	n.Deadline = time.Time{}
+	assert.Nil(t, sink.Send(context.Background(), n))
+
+	assert.Equal(t, "mk-giveaways", received.Topic)
+	assert.Equal(t, "GMK Olivia [Giveaway]", received.Title)
+	assert.Equal(t, "https://old.reddit.com/r/MechanicalKeyboards/comments/abc/gmk_olivia_giveaway/", received.Click)
+	assert.Equal(t, ntfyDefaultPriority, received.Priority)
+	assert.Equal(t, []string{"giveaway"}, received.Tags)
+	assert.Contains(t, received.Message, "by u/someone in r/MechanicalKeyboards")
+	assert.Empty(t, headers.Get("Authorization"))
+
+	// a giveaway ending soon is pushed with a high priority
+	n.Deadline = time.Now().Add(time.Hour)
+	assert.Nil(t, sink.Send(context.Background(), n))
+	assert.Equal(t, ntfyHighPriority, received.Priority)
+}
+
+func TestNtfyPriorities(t *testing.T) {
+	var received ntfyMessage
+	var headers http.Header
+	server := newPushServer(t, "/topics/", &received, &headers)
+	defer server.Close()
+
+	config, err := Parse(TypeNtfy, `{"url": "`+server.URL+`/topics/mk", "token": "tk_secret", "priority": 2, "soon_priority": 5, "soon": "2h"}`)
+	assert.Nil(t, err)
+	sink, err := New(config)
+	assert.Nil(t, err)
+
+	n := testNotification()
+	n.Deadline = time.Now().Add(3 * time.Hour)
+	assert.Nil(t, sink.Send(context.Background(), n))
+	assert.Equal(t, "mk", received.Topic)
+	assert.Equal(t, 2, received.Priority)
+	assert.Equal(t, "Bearer tk_secret", headers.Get("Authorization"))
+
+	n.Deadline = time.Now().Add(time.Hour)
+	assert.Nil(t, sink.Send(context.Background(), n))
+	assert.Equal(t, 5, received.Priority)
+
+	// a giveaway which is over isn't urgent anymore
+	n.Deadline = time.Now().Add(-time.Hour)
+	assert.Nil(t, sink.Send(context.Background(), n))
+	assert.Equal(t, 2, received.Priority)
+
+	_, err = Parse(TypeNtfy, `{"url": "https://ntfy.sh/mk", "priority": 6}`)
+	assert.NotNil(t, err)
+	_, err = Parse(TypeNtfy, `{"url": "https://ntfy.sh/mk", "soon_priority": 0}`)
+	assert.NotNil(t, err)
+	_, err = Parse(TypeNtfy, `{"url": "https://ntfy.sh/mk", "soon": "soon"}`)
+	assert.NotNil(t, err)
+	_, err = Parse(TypeNtfy, "https://ntfy.sh/")
+	assert.NotNil(t, err)
+}
+
+func TestGotify(t *testing.T) {
+	var received gotifyMessage
+	var headers http.Header
+	server := newPushServer(t, "/message", &received, &headers)
+	defer server.Close()
+
+	config, err := Parse(TypeGotify, `{"url": "`+server.URL+`", "token": "app-token", "soon_priority": 10, "soon": "2d"}`)
+	assert.Nil(t, err)
+	sink, err := New(config)
+	assert.Nil(t, err)
+
+	n := testNotification()
+	n.Deadline = time.Now().Add(36 * time.Hour)
+	assert.Nil(t, sink.Send(context.Background(), n))
+
+	assert.Equal(t, "app-token", headers.Get("X-Gotify-Key"))
+	assert.Equal(t, "GMK Olivia [Giveaway]", received.Title)
+	assert.Equal(t, 10, received.Priority)
+	assert.Contains(t, received.Message, "\nTags: giveaway")
+	click := received.Extras["client::notification"].(map[string]interface{})["click"].(map[string]interface{})
+	assert.Equal(t, "https://old.reddit.com/r/MechanicalKeyboards/comments/abc/gmk_olivia_giveaway/", click["url"])
+
+	n.Deadline = time.Time{}
+	assert.Nil(t, sink.Send(context.Background(), n))
+	assert.Equal(t, gotifyDefaultPriority, received.Priority)
+
+	// 0 is the lowest priority of Gotify, not the default
+	config, err = Parse(TypeGotify, `{"url": "`+server.URL+`", "token": "app-token", "priority": 0}`)
+	assert.Nil(t, err)
+	sink, err = New(config)
+	assert.Nil(t, err)
+	assert.Nil(t, sink.Send(context.Background(), n))
+	assert.Equal(t, 0, received.Priority)
+
+	_, err = Parse(TypeGotify, `{"url": "`+server.URL+`", "token": "app-token", "priority": -1}`)
+	assert.NotNil(t, err)
+	_, err = Parse(TypeGotify, server.URL)
+	assert.NotNil(t, err)
+}
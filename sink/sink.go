@@ -0,0 +1,282 @@
+// sink delivers the notifications of the giveaways to the places where they
+// are read: a Telegram chat, a Discord channel, ...
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/giveaway"
+)
+
+// the types of sink
+const (
+	// TypeTelegram is the chat owning the subscription, it is created by the
+	// bot and not by New
+	TypeTelegram = "telegram"
+	TypeDiscord  = "discord"
+	TypeEmail    = "email"
+	TypeWebhook  = "webhook"
+	TypeMatrix   = "matrix"
+	TypeNtfy     = "ntfy"
+	TypeGotify   = "gotify"
+)
+
+// Types lists the types of sink which can be configured
+var Types = []string{TypeTelegram, TypeDiscord, TypeEmail, TypeWebhook, TypeMatrix, TypeNtfy, TypeGotify}
+
+// RedditURL is the prefix of the links to the posts
+const RedditURL = "https://old.reddit.com"
+
+// requestTimeout is the default timeout of the HTTP requests of the sinks
+const requestTimeout = 30 * time.Second
+
+// defaultClient is used by the sinks without Client
+var defaultClient = newDefaultClient()
+
+// Notification is a rendered giveaway notification, independent of the sink
+// delivering it
+type Notification struct {
+	// ID is the full ID of the post (e.g. "t3_abc123")
+	ID        string
+	Title     string
+	Author    string
+	Subreddit string
+	// Permalink is the path of the post on reddit, see URL
+	Permalink string
+	Created   time.Time
+	// Deadline is the end of the giveaway, zero if unknown
+	Deadline       time.Time
+	Eligibility    giveaway.Eligibility
+	Classification giveaway.Classification
+}
+
+// URL returns the link to the post on old.reddit.com
+func (n *Notification) URL() string {
+	return RedditURL + n.Permalink
+}
+
+// AuthorURL returns the link to the profile of the author
+func (n *Notification) AuthorURL() string {
+	return RedditURL + "/user/" + n.Author
+}
+
+// Details returns the lines describing the giveaway after its title: its
+// deadline, its regions and the classification, the unknown ones omitted
+func (n *Notification) Details() []string {
+	var lines []string
+	if !n.Deadline.IsZero() {
+		lines = append(lines, "Ends "+n.Deadline.Local().Format("Mon Jan 2 15:04"))
+	}
+	if n.Eligibility.Known() {
+		lines = append(lines, "Open to "+n.Eligibility.String())
+	}
+	return append(lines, "Matched: "+n.Classification.String())
+}
+
+// Text renders the notification as plain text
+func (n *Notification) Text() string {
+	lines := []string{
+		fmt.Sprintf("%s by u/%s", n.Title, n.Author),
+		"old.reddit.com" + n.Permalink,
+	}
+	return strings.Join(append(lines, n.Details()...), "\n")
+}
+
+// HTML renders the notification as an HTML fragment, the title linking to the
+// post
+func (n *Notification) HTML() string {
+	lines := []string{fmt.Sprintf(
+		`<a href="%s">%s</a> by <a href="%s">u/%s</a>`,
+		html.EscapeString(n.URL()), html.EscapeString(n.Title),
+		html.EscapeString(n.AuthorURL()), html.EscapeString(n.Author),
+	)}
+	for _, line := range n.Details() {
+		lines = append(lines, html.EscapeString(line))
+	}
+	return strings.Join(lines, "<br>")
+}
+
+// Sink delivers notifications
+type Sink interface {
+	Send(ctx context.Context, n *Notification) error
+}
+
+// Flusher is implemented by the sinks grouping the notifications: Flush
+// delivers the notifications sent since its last call. It is called after
+// each update of a feed. If Flush fails, none of these notifications were
+// delivered.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Config describes a sink, as stored with the subscriptions
+type Config struct {
+	Type string `json:"type"`
+	// Params are the settings of the sink, their format depends on Type
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// String describes the sink without its secrets: its type and the host of its
+// URL or server, if any
+func (c Config) String() string {
+	var params struct {
+		URL        string `json:"url"`
+		Homeserver string `json:"homeserver"`
+		Host       string `json:"host"`
+	}
+	if json.Unmarshal(c.Params, &params) != nil {
+		return c.Type
+	}
+
+	host := params.Host
+	for _, raw := range []string{params.URL, params.Homeserver} {
+		if u, err := url.Parse(raw); err == nil && len(u.Host) > 0 {
+			host = u.Host
+		}
+	}
+	if len(host) == 0 {
+		return c.Type
+	}
+	return fmt.Sprintf("%s (%s)", c.Type, host)
+}
+
+// Parse returns the configuration of a sink of type `kind` from the parameters
+// given by a user: a JSON object, or the URL of the sinks having an "url"
+// parameter. The configuration is checked with New.
+func Parse(kind, params string) (Config, error) {
+	config := Config{Type: strings.ToLower(kind)}
+
+	params = strings.TrimSpace(params)
+	switch {
+	case len(params) == 0:
+	case strings.HasPrefix(params, "{"):
+		config.Params = json.RawMessage(params)
+	default:
+		data, err := json.Marshal(map[string]string{"url": params})
+		if err != nil {
+			return Config{}, err
+		}
+		config.Params = data
+	}
+
+	if config.Type == TypeTelegram {
+		if len(config.Params) > 0 {
+			return Config{}, fmt.Errorf("the %s sink has no parameter", TypeTelegram)
+		}
+		return config, nil
+	}
+
+	_, err := New(config)
+	if err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// New returns the sink described by `config`
+func New(config Config) (Sink, error) {
+	var sink interface {
+		Sink
+		check() error
+	}
+
+	switch config.Type {
+	case TypeDiscord:
+		sink = &Discord{}
+	case TypeEmail:
+		sink = &Email{}
+	case TypeWebhook:
+		sink = &Webhook{}
+	case TypeMatrix:
+		sink = &Matrix{}
+	case TypeNtfy:
+		sink = &Ntfy{}
+	case TypeGotify:
+		sink = &Gotify{}
+	case TypeTelegram:
+		return nil, fmt.Errorf("the %s sink is created by the bot", TypeTelegram)
+	default:
+		return nil, fmt.Errorf("unknown sink %q (available: %s)", config.Type, strings.Join(Types, ", "))
+	}
+
+	if len(config.Params) > 0 {
+		err := json.Unmarshal(config.Params, sink)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameters for the %s sink: %v", config.Type, err)
+		}
+	}
+
+	err := sink.check()
+	if err != nil {
+		return nil, fmt.Errorf("invalid parameters for the %s sink: %v", config.Type, err)
+	}
+	return sink, nil
+}
+
+// checkURL returns an error if `raw` isn't an absolute http(s) URL
+func checkURL(raw string) error {
+	if len(raw) == 0 {
+		return fmt.Errorf("url is required")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return fmt.Errorf("%q is not an http(s) URL", raw)
+	}
+	return nil
+}
+
+// StatusError is returned when a service replies with an unexpected status
+type StatusError struct {
+	StatusCode int
+	// Body is the beginning of the response
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	if len(e.Body) == 0 {
+		return fmt.Sprintf("unexpected status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary returns true if the request may succeed later
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// maxErrorBody is the length of the response kept in a StatusError
+const maxErrorBody = 512
+
+// do sends the request with `client` (the default client if nil) and returns
+// a StatusError if the response isn't a success
+func do(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = defaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if len(body) > maxErrorBody {
+			body = body[:maxErrorBody]
+		}
+		return &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package sink
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/giveaway"
+	"github.com/stretchr/testify/assert"
+)
+
+func testNotification() *Notification {
+	return &Notification{
+		ID:          "t3_abc",
+		Title:       "GMK Olivia [Giveaway]",
+		Author:      "someone",
+		Subreddit:   "MechanicalKeyboards",
+		Permalink:   "/r/MechanicalKeyboards/comments/abc/gmk_olivia_giveaway/",
+		Created:     time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC),
+		Deadline:    time.Date(2021, 6, 4, 17, 0, 0, 0, time.UTC),
+		Eligibility: giveaway.Eligibility{Regions: []giveaway.Region{giveaway.US}},
+		Classification: giveaway.DefaultClassifier().Classify(
+			"GMK Olivia [Giveaway]", "", "",
+		),
+	}
+}
+
+func TestParse(t *testing.T) {
+	config, err := Parse("Discord", "https://discord.com/api/webhooks/1/token")
+	assert.Nil(t, err)
+	assert.Equal(t, TypeDiscord, config.Type)
+	assert.JSONEq(t, `{"url": "https://discord.com/api/webhooks/1/token"}`, string(config.Params))
+
+	config, err = Parse("telegram", "")
+	assert.Nil(t, err)
+	assert.Equal(t, Config{Type: TypeTelegram}, config)
+
+	_, err = Parse("discord", "not a url")
+	assert.NotNil(t, err)
+	_, err = Parse("discord", `{"url": 1}`)
+	assert.NotNil(t, err)
+	_, err = Parse("pigeon", "")
+	assert.NotNil(t, err)
+}
+
+func TestNotificationText(t *testing.T) {
+	n := testNotification()
+	n.Deadline = time.Time{}
+	assert.Equal(t,
+		"GMK Olivia [Giveaway] by u/someone\n"+
+			"old.reddit.com/r/MechanicalKeyboards/comments/abc/gmk_olivia_giveaway/\n"+
+			"Open to us\n"+
+			"Matched: "+n.Classification.String(),
+		n.Text(),
+	)
+}
@@ -0,0 +1,243 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PayloadVersion is the version of the JSON payload of the webhooks, it
+// changes when fields are removed or change meaning
+const PayloadVersion = 1
+
+// the headers of the webhook requests
+const (
+	// SignatureHeader holds "sha256=" followed by the hexadecimal HMAC-SHA256
+	// of the body, keyed by the secret of the webhook
+	SignatureHeader = "X-Giveaway-Signature-256"
+	// VersionHeader holds PayloadVersion
+	VersionHeader = "X-Giveaway-Version"
+)
+
+// the default retry policy of the webhooks. A delivery, retries included,
+// takes at most DefaultDeliveryTimeout so that an unreachable webhook doesn't
+// hold back the other notifications.
+const (
+	DefaultAttempts        = 5
+	DefaultBackoff         = 250 * time.Millisecond
+	DefaultDeliveryTimeout = 5 * time.Second
+)
+
+// Webhook POSTs a JSON payload describing each giveaway to a URL, signed with
+// an HMAC of a shared secret. Failed deliveries are retried with an
+// exponential backoff within DeliveryTimeout, and recorded in DeadLetters once
+// every attempt failed.
+type Webhook struct {
+	URL string `json:"url"`
+	// Secret is the key of the signature of the payloads (see SignatureHeader)
+	Secret string `json:"secret"`
+	// Attempts is the number of deliveries tried, DefaultAttempts if zero
+	Attempts int `json:"attempts,omitempty"`
+	// Backoff is the delay before the first retry, it doubles after each
+	// retry. DefaultBackoff is used if zero.
+	Backoff time.Duration `json:"-"`
+	// DeliveryTimeout bounds the time spent on a notification, retries
+	// included. DefaultDeliveryTimeout is used if zero.
+	DeliveryTimeout time.Duration `json:"-"`
+	// Client sends the requests, a client with a 30s timeout is used if nil
+	Client *http.Client `json:"-"`
+	// DeadLetters records the deliveries which never succeeded, if not nil.
+	// It is set by the bot.
+	DeadLetters DeadLetters `json:"-"`
+}
+
+// Payload is the body of the webhook requests
+type Payload struct {
+	Version   int       `json:"version"`
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Author    string    `json:"author"`
+	Subreddit string    `json:"subreddit"`
+	Permalink string    `json:"permalink"`
+	Created   time.Time `json:"created"`
+	// Deadline is omitted if unknown
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// Regions is empty if unknown
+	Regions  []string `json:"regions,omitempty"`
+	Excluded []string `json:"excluded,omitempty"`
+	Score    float64  `json:"score"`
+	Reasons  []Reason `json:"reasons"`
+}
+
+// Reason is a rule of the classifier matching the post
+type Reason struct {
+	Rule   string  `json:"rule"`
+	Field  string  `json:"field,omitempty"`
+	Weight float64 `json:"weight"`
+	Text   string  `json:"text"`
+}
+
+// NewPayload returns the payload of a notification
+func NewPayload(n *Notification) *Payload {
+	payload := &Payload{
+		Version:   PayloadVersion,
+		ID:        n.ID,
+		Title:     n.Title,
+		Author:    n.Author,
+		Subreddit: n.Subreddit,
+		Permalink: n.URL(),
+		Created:   n.Created.UTC(),
+		Score:     n.Classification.Score,
+		Reasons:   make([]Reason, len(n.Classification.Matches)),
+	}
+	if !n.Deadline.IsZero() {
+		deadline := n.Deadline.UTC()
+		payload.Deadline = &deadline
+	}
+	for _, region := range n.Eligibility.Regions {
+		payload.Regions = append(payload.Regions, string(region))
+	}
+	for _, region := range n.Eligibility.Excluded {
+		payload.Excluded = append(payload.Excluded, string(region))
+	}
+	for i, match := range n.Classification.Matches {
+		payload.Reasons[i] = Reason{
+			Rule:   match.Rule,
+			Field:  string(match.Field),
+			Weight: match.Weight,
+			Text:   match.Text,
+		}
+	}
+	return payload
+}
+
+// Sign returns the value of SignatureHeader for `body`
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// DeadLetter is a delivery which never succeeded
+type DeadLetter struct {
+	// Type is the type of the sink
+	Type     string          `json:"type"`
+	URL      string          `json:"url"`
+	Payload  json.RawMessage `json:"payload"`
+	Attempts int             `json:"attempts"`
+	// Error is the error of the last attempt
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// DeadLetters stores the dead letters
+type DeadLetters interface {
+	Record(letter DeadLetter) error
+}
+
+func (w *Webhook) check() error {
+	if len(w.Secret) == 0 {
+		return fmt.Errorf("secret is required")
+	}
+	if w.Attempts < 0 {
+		return fmt.Errorf("attempts must not be negative")
+	}
+	return checkURL(w.URL)
+}
+
+// Send POSTs the payload of the notification, retrying the temporary
+// failures until DeliveryTimeout. A dead letter is recorded if it never
+// succeeds.
+func (w *Webhook) Send(ctx context.Context, n *Notification) error {
+	body, err := json.Marshal(NewPayload(n))
+	if err != nil {
+		return err
+	}
+
+	timeout := w.DeliveryTimeout
+	if timeout == 0 {
+		timeout = DefaultDeliveryTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	attempts := w.Attempts
+	if attempts == 0 {
+		attempts = DefaultAttempts
+	}
+	backoff := w.Backoff
+	if backoff == 0 {
+		backoff = DefaultBackoff
+	}
+
+	attempt := 1
+	for ; ; attempt++ {
+		err = w.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if statusErr, ok := err.(*StatusError); ok && !statusErr.Temporary() {
+			break
+		}
+		// a refused destination stays refused
+		var destErr *DestinationError
+		if errors.As(err, &destErr) {
+			break
+		}
+		if attempt == attempts {
+			break
+		}
+		// don't wait for a retry which can't happen
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+			break
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		backoff *= 2
+	}
+
+	if w.DeadLetters != nil {
+		recordErr := w.DeadLetters.Record(DeadLetter{
+			Type:     TypeWebhook,
+			URL:      w.URL,
+			Payload:  body,
+			Attempts: attempt,
+			Error:    PublicError(err),
+			Time:     time.Now(),
+		})
+		if recordErr != nil {
+			return fmt.Errorf("%v (unable to record the dead letter: %v)", err, recordErr)
+		}
+	}
+	return err
+}
+
+// post sends one request with the payload
+func (w *Webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(VersionHeader, strconv.Itoa(PayloadVersion))
+	req.Header.Set(SignatureHeader, Sign(w.Secret, body))
+
+	return do(w.Client, req)
+}
@@ -0,0 +1,154 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// letters is an in memory DeadLetters
+type letters struct {
+	records []DeadLetter
+}
+
+func (l *letters) Record(letter DeadLetter) error {
+	l.records = append(l.records, letter)
+	return nil
+}
+
+// webhookServer replies with the statuses in order, then 204
+type webhookServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	statuses []int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func newWebhookServer(statuses ...int) *webhookServer {
+	server := &webhookServer{statuses: statuses}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		server.bodies = append(server.bodies, body)
+		server.headers = append(server.headers, r.Header)
+
+		status := http.StatusNoContent
+		if len(server.statuses) > 0 {
+			status, server.statuses = server.statuses[0], server.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	return server
+}
+
+func TestWebhook(t *testing.T) {
+	server := newWebhookServer()
+	defer server.Close()
+
+	webhook := &Webhook{URL: server.URL, Secret: "secret"}
+	assert.Nil(t, webhook.Send(context.Background(), testNotification()))
+	assert.Len(t, server.bodies, 1)
+
+	body := server.bodies[0]
+	assert.Equal(t, Sign("secret", body), server.headers[0].Get(SignatureHeader))
+	assert.Equal(t, "1", server.headers[0].Get(VersionHeader))
+
+	var payload map[string]interface{}
+	assert.Nil(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, float64(PayloadVersion), payload["version"])
+	assert.Equal(t, "t3_abc", payload["id"])
+	assert.Equal(t, "GMK Olivia [Giveaway]", payload["title"])
+	assert.Equal(t, "someone", payload["author"])
+	assert.Equal(t, "MechanicalKeyboards", payload["subreddit"])
+	assert.Equal(t, "https://old.reddit.com/r/MechanicalKeyboards/comments/abc/gmk_olivia_giveaway/", payload["permalink"])
+	assert.Equal(t, "2021-06-01T12:00:00Z", payload["created"])
+	assert.Equal(t, "2021-06-04T17:00:00Z", payload["deadline"])
+	assert.Equal(t, []interface{}{"us"}, payload["regions"])
+
+	reasons := payload["reasons"].([]interface{})
+	assert.NotEmpty(t, reasons)
+	assert.Equal(t, "giveaway", reasons[0].(map[string]interface{})["rule"])
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"version":1}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t,
+		"sha256=5bf41a7738bdf2b8c02d61765ceaf8e6fb4f1f2973b7db89386a3f399fba16bf",
+		Sign("secret", []byte(`{"version":1}`)),
+	)
+}
+
+func TestWebhookRetry(t *testing.T) {
+	server := newWebhookServer(http.StatusInternalServerError, http.StatusTooManyRequests)
+	defer server.Close()
+
+	dead := &letters{}
+	webhook := &Webhook{URL: server.URL, Secret: "secret", Backoff: time.Millisecond, DeadLetters: dead}
+	assert.Nil(t, webhook.Send(context.Background(), testNotification()))
+	assert.Len(t, server.bodies, 3)
+	assert.Empty(t, dead.records)
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	server := newWebhookServer(http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable)
+	defer server.Close()
+
+	dead := &letters{}
+	webhook := &Webhook{URL: server.URL, Secret: "secret", Attempts: 3, Backoff: time.Millisecond, DeadLetters: dead}
+	err := webhook.Send(context.Background(), testNotification())
+	assert.IsType(t, &StatusError{}, err)
+	assert.Len(t, server.bodies, 3)
+
+	assert.Len(t, dead.records, 1)
+	letter := dead.records[0]
+	assert.Equal(t, TypeWebhook, letter.Type)
+	assert.Equal(t, server.URL, letter.URL)
+	assert.Equal(t, 3, letter.Attempts)
+	assert.Equal(t, string(server.bodies[0]), string(letter.Payload))
+	assert.Equal(t, err.Error(), letter.Error)
+
+	// the client errors are not retried
+	server.mutex.Lock()
+	server.statuses = []int{http.StatusNotFound}
+	server.mutex.Unlock()
+	assert.NotNil(t, webhook.Send(context.Background(), testNotification()))
+	assert.Len(t, server.bodies, 4)
+	assert.Len(t, dead.records, 2)
+	assert.Equal(t, 1, dead.records[1].Attempts)
+}
+
+func TestWebhookDeliveryTimeout(t *testing.T) {
+	// the server doesn't answer before the end of the test
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	dead := &letters{}
+	webhook := &Webhook{URL: server.URL, Secret: "secret", DeliveryTimeout: 100 * time.Millisecond, DeadLetters: dead}
+	start := time.Now()
+	assert.NotNil(t, webhook.Send(context.Background(), testNotification()))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	assert.Len(t, dead.records, 1)
+	assert.Equal(t, 1, dead.records[0].Attempts)
+}
+
+func TestWebhookCheck(t *testing.T) {
+	_, err := Parse(TypeWebhook, "https://example.com/hook")
+	assert.NotNil(t, err)
+	_, err = Parse(TypeWebhook, `{"url": "https://example.com/hook", "secret": "secret"}`)
+	assert.Nil(t, err)
+}
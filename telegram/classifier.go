@@ -0,0 +1,25 @@
+package telegram
+
+import (
+	"github.com/maxime915/mk-giveaway-notifier/giveaway"
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+)
+
+// classify scores `post` with the classifier of the bot, mixed with the model
+// trained from the labels once Launch is called
+func (b *TelegramNotifier) classify(post *reddit.Post) giveaway.Classification {
+	b.classifierMutex.RLock()
+	classifier := b.classifier
+	b.classifierMutex.RUnlock()
+
+	if classifier == nil {
+		classifier = b.Classifier
+	}
+	return classifier.Classify(post.Title, post.Flair, post.Body)
+}
+
+// isGiveaway returns true if the classifier of the bot considers `post` as a
+// giveaway
+func (b *TelegramNotifier) isGiveaway(post *reddit.Post) bool {
+	return b.classify(post).Giveaway
+}
@@ -0,0 +1,143 @@
+package telegram
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/sink"
+	bolt "go.etcd.io/bbolt"
+	telegram "gopkg.in/tucnak/telebot.v2"
+)
+
+// the dead letter bucket holds a bucket per chat, mapping a sequence number to
+// a sink.DeadLetter
+const deadLetterBucketName = "dead-letter-bucket"
+
+// maxDeadLetters is the number of dead letters kept per chat, the oldest are
+// dropped
+const maxDeadLetters = 100
+
+// maxDeadLettersListed is the maximum number of dead letters listed by
+// /deadletters
+const maxDeadLettersListed = 10
+
+// chatDeadLetters stores the dead letters of the sinks of a chat
+type chatDeadLetters struct {
+	b      *TelegramNotifier
+	chatID int64
+}
+
+func (d chatDeadLetters) Record(letter sink.DeadLetter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	return d.b.db.Update(func(t *bolt.Tx) error {
+		bucket, err := t.Bucket([]byte(deadLetterBucketName)).CreateBucketIfNotExists(chatKey(d.chatID))
+		if err != nil {
+			return err
+		}
+
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+
+		err = bucket.Put(key, data)
+		if err != nil {
+			return err
+		}
+
+		// drop the oldest letters
+		var keys [][]byte
+		cursor := bucket.Cursor()
+		for k, _ := cursor.Last(); k != nil; k, _ = cursor.Prev() {
+			keys = append(keys, append([]byte{}, k...))
+		}
+		for len(keys) > maxDeadLetters {
+			err = bucket.Delete(keys[len(keys)-1])
+			if err != nil {
+				return err
+			}
+			keys = keys[:len(keys)-1]
+		}
+		return nil
+	})
+}
+
+// deadLetters returns the dead letters of chatID, newest first
+func (b *TelegramNotifier) deadLetters(chatID int64) ([]sink.DeadLetter, error) {
+	var letters []sink.DeadLetter
+
+	err := b.db.View(func(t *bolt.Tx) error {
+		bucket := t.Bucket([]byte(deadLetterBucketName)).Bucket(chatKey(chatID))
+		if bucket == nil {
+			return nil
+		}
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			var letter sink.DeadLetter
+			err := json.Unmarshal(v, &letter)
+			if err != nil {
+				return err
+			}
+			letters = append(letters, letter)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return letters, nil
+}
+
+// deleteDeadLetters removes the dead letters of chatID in the transaction, if
+// any
+func deleteDeadLetters(t *bolt.Tx, chatID int64) error {
+	err := t.Bucket([]byte(deadLetterBucketName)).DeleteBucket(chatKey(chatID))
+	if err == bolt.ErrBucketNotFound {
+		return nil
+	}
+	return err
+}
+
+// replyDeadLetters lists the most recent dead letters of the chat
+func (b *TelegramNotifier) replyDeadLetters(m *telegram.Message) error {
+	letters, err := b.deadLetters(m.Chat.ID)
+	if err != nil {
+		b.Send(m.Sender, "Unable to read the failed deliveries, see logs for detail.")
+		return err
+	}
+
+	message := fmt.Sprintf("%d delivery(ies) failed.", len(letters))
+	if len(letters) > maxDeadLettersListed {
+		letters = letters[:maxDeadLettersListed]
+	}
+	for _, letter := range letters {
+		var payload struct {
+			ID string `json:"id"`
+		}
+		json.Unmarshal(letter.Payload, &payload)
+
+		host := letter.URL
+		if u, err := url.Parse(letter.URL); err == nil {
+			host = u.Host
+		}
+
+		message += fmt.Sprintf(
+			"\n%s to %s (%s) at %s after %d attempt(s): %s",
+			payload.ID, host, letter.Type, letter.Time.Local().Format(time.Stamp), letter.Attempts, letter.Error,
+		)
+	}
+
+	_, err = b.Send(m.Sender, message)
+	return err
+}
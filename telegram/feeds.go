@@ -0,0 +1,240 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	bolt "go.etcd.io/bbolt"
+	telegram "gopkg.in/tucnak/telebot.v2"
+)
+
+// DefaultFeedName is the name of the feed subscribed to without a name, and
+// of the feeds stored before the feeds had names
+const DefaultFeedName = "default"
+
+// feedNameRx matches the valid feed names
+var feedNameRx = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// reservedFeedNames are the arguments of the commands which can't be told
+// apart from a feed name, e.g. /filter off or /sink add
+var reservedFeedNames = map[string]bool{"off": true, "add": true, "remove": true}
+
+// feedKey returns the key of the feed `name` of chatID in the main bucket:
+// the key of the chat followed by the name, so that the feeds of a chat are
+// stored next to each other.
+func feedKey(chatID int64, name string) []byte {
+	return append(chatKey(chatID), name...)
+}
+
+// parseFeedKey returns the chat and the name of the feed stored at `key`
+func parseFeedKey(key []byte) (int64, string) {
+	return int64(binary.BigEndian.Uint64(key[:8])), string(key[8:])
+}
+
+// feedID identifies a feed of a chat
+type feedID struct {
+	chatID int64
+	name   string
+}
+
+// migrateFeeds renames the feeds stored before the feeds had names (stored at
+// the key of the chat) to DefaultFeedName
+func migrateFeeds(t *bolt.Tx) error {
+	bucket := t.Bucket([]byte(bucketName))
+
+	var legacy [][]byte
+	err := bucket.ForEach(func(k, v []byte) error {
+		if len(k) == 8 {
+			legacy = append(legacy, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, k := range legacy {
+		chatID, _ := parseFeedKey(k)
+		err = bucket.Put(feedKey(chatID, DefaultFeedName), bucket.Get(k))
+		if err != nil {
+			return err
+		}
+		err = bucket.Delete(k)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// feedNames returns the sorted names of the feeds of chatID
+func feedNames(t *bolt.Tx, chatID int64) []string {
+	prefix := chatKey(chatID)
+	cursor := t.Bucket([]byte(bucketName)).Cursor()
+
+	var names []string
+	for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+		_, name := parseFeedKey(k)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// feeds returns the sorted names of the feeds of chatID
+func (b *TelegramNotifier) feeds(chatID int64) ([]string, error) {
+	var names []string
+	err := b.db.View(func(t *bolt.Tx) error {
+		names = feedNames(t, chatID)
+		return nil
+	})
+	return names, err
+}
+
+// selectFeeds returns the feeds of chatID targeted by a command and the rest
+// of its payload. If the payload starts with the name of a feed, that feed is
+// selected. Otherwise every feed is selected if `all` is true, and the only
+// feed of the chat if `all` is false: an AmbiguousFeedError is returned if
+// the chat has several feeds. A payload made of one of `arguments` alone is
+// an argument of the command, not a feed name (e.g. /interval default).
+func (b *TelegramNotifier) selectFeeds(chatID int64, payload string, all bool, arguments ...string) ([]string, string, error) {
+	names, err := b.feeds(chatID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(names) == 0 {
+		return nil, "", KeyNotFoundError{}
+	}
+
+	payload = strings.TrimSpace(payload)
+	first := strings.ToLower(strings.SplitN(payload, " ", 2)[0])
+	for _, argument := range arguments {
+		if strings.EqualFold(payload, argument) {
+			first = ""
+		}
+	}
+	for _, name := range names {
+		if name == first {
+			return []string{name}, strings.TrimSpace(payload[len(first):]), nil
+		}
+	}
+
+	if len(names) > 1 && !all {
+		return nil, "", AmbiguousFeedError{}
+	}
+	return names, payload, nil
+}
+
+// replyFeedError replies to the errors of selectFeeds, it returns the errors
+// which are not explained to the user
+func (b *TelegramNotifier) replyFeedError(m *telegram.Message, err error) error {
+	switch err.(type) {
+	case KeyNotFoundError:
+		_, err = b.Send(m.Sender, "You are not subscribed to any feed.")
+		return err
+	case AmbiguousFeedError:
+		names, err := b.feeds(m.Chat.ID)
+		if err != nil {
+			return err
+		}
+		_, err = b.Send(m.Sender, fmt.Sprintf(
+			"You have several feeds (%s), give the name of the feed first (e.g. %s %s ...).",
+			strings.Join(names, ", "), strings.Fields(m.Text)[0], names[0],
+		))
+		return err
+	default:
+		b.Send(m.Sender, "Unable to read your feeds, see logs for detail.")
+		return err
+	}
+}
+
+// feedLabel returns the name of the feed as shown in the Markdown replies,
+// empty for the default feed
+func feedLabel(name string) string {
+	if name == DefaultFeedName {
+		return ""
+	}
+	return fmt.Sprintf(" on *%s*", name)
+}
+
+// feedLabelHTML is feedLabel for the messages sent in HTML
+func feedLabelHTML(name string) string {
+	if name == DefaultFeedName {
+		return ""
+	}
+	return fmt.Sprintf(" on <b>%s</b>", html.EscapeString(name))
+}
+
+// replyFeeds lists the feeds of the chat
+func (b *TelegramNotifier) replyFeeds(m *telegram.Message) error {
+	var lines []string
+	err := b.db.View(func(t *bolt.Tx) error {
+		bucket := t.Bucket([]byte(bucketName))
+		for _, name := range feedNames(t, m.Chat.ID) {
+			var sub *subscription
+			err := json.Unmarshal(bucket.Get(feedKey(m.Chat.ID, name)), &sub)
+			if err != nil {
+				return err
+			}
+
+			interval := sub.Interval
+			if interval == 0 {
+				interval = b.PollInterval
+			}
+			line := fmt.Sprintf("%s: r/%s every %v", name, sub.Subreddits, interval)
+			if len(sub.Filter) > 0 {
+				line += ", filter: " + sub.Filter
+			}
+			lines = append(lines, line)
+		}
+		return nil
+	})
+	if err != nil {
+		b.Send(m.Sender, "Unable to read your feeds, see logs for detail.")
+		return err
+	}
+
+	if len(lines) == 0 {
+		_, err = b.Send(m.Sender, "You are not subscribed to any feed, see /subscribe.")
+		return err
+	}
+
+	_, err = b.Send(m.Sender, strings.Join(lines, "\n"))
+	return err
+}
+
+// parseSubscribe splits the payload of /subscribe into the name of the feed
+// and its subreddits: "<name> <subreddit>..." or "<subreddit>" for a feed
+// named after its subreddit. The default feed follows the default subreddit.
+func parseSubscribe(payload string) (string, string, error) {
+	words := strings.Fields(strings.ReplaceAll(payload, ",", " "))
+	switch len(words) {
+	case 0:
+		return DefaultFeedName, "", nil
+	case 1:
+		name := strings.ToLower(strings.ReplaceAll(reddit.TrimSubredditName(words[0]), "_", "-"))
+		if !feedNameRx.MatchString(name) {
+			return "", "", fmt.Errorf("%q is not a valid feed name", words[0])
+		}
+		if reservedFeedNames[name] {
+			return "", "", fmt.Errorf("%q is a reserved word, give the feed another name", name)
+		}
+		return name, words[0], nil
+	}
+
+	name := strings.ToLower(words[0])
+	if !feedNameRx.MatchString(name) {
+		return "", "", fmt.Errorf("%q is not a valid feed name (up to 32 letters, digits or dashes)", words[0])
+	}
+	if reservedFeedNames[name] {
+		return "", "", fmt.Errorf("%q is a reserved word, give the feed another name", name)
+	}
+	return name, strings.Join(words[1:], " "), nil
+}
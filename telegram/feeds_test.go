@@ -0,0 +1,125 @@
+package telegram
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+// getTestNotifier returns a notifier without telegram bot, using a temporary
+// database
+func getTestNotifier(t *testing.T) *TelegramNotifier {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "bot.db"), 0666, nil)
+	assert.Nil(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	b := newEmptyBot()
+	b.db = db
+	assert.Nil(t, b.initDB())
+	return b
+}
+
+func TestMigrateFeeds(t *testing.T) {
+	b := getTestNotifier(t)
+
+	err := b.db.Update(func(t *bolt.Tx) error {
+		bucket := t.Bucket([]byte(bucketName))
+		err := bucket.Put(chatKey(1), []byte(`{"url": "MechanicalKeyboards"}`))
+		if err != nil {
+			return err
+		}
+		err = bucket.Put(feedKey(2, "trades"), []byte(`{"url": "mechmarket"}`))
+		if err != nil {
+			return err
+		}
+		return migrateFeeds(t)
+	})
+	assert.Nil(t, err)
+
+	names, err := b.feeds(1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{DefaultFeedName}, names)
+
+	sub, err := b.subscription(1, DefaultFeedName)
+	assert.Nil(t, err)
+	assert.Equal(t, "MechanicalKeyboards", sub.Subreddits)
+
+	names, err = b.feeds(2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"trades"}, names)
+}
+
+func TestSelectFeeds(t *testing.T) {
+	b := getTestNotifier(t)
+
+	_, _, err := b.selectFeeds(1, "", true)
+	assert.Equal(t, KeyNotFoundError{}, err)
+
+	err = b.db.Update(func(t *bolt.Tx) error {
+		bucket := t.Bucket([]byte(bucketName))
+		for _, name := range []string{"trades", DefaultFeedName} {
+			err := bucket.Put(feedKey(1, name), []byte(`{}`))
+			if err != nil {
+				return err
+			}
+		}
+		return bucket.Put(feedKey(2, DefaultFeedName), []byte(`{}`))
+	})
+	assert.Nil(t, err)
+
+	names, rest, err := b.selectFeeds(1, "Trades 6h", false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"trades"}, names)
+	assert.Equal(t, "6h", rest)
+
+	names, rest, err = b.selectFeeds(1, "6h", true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{DefaultFeedName, "trades"}, names)
+	assert.Equal(t, "6h", rest)
+
+	_, _, err = b.selectFeeds(1, "6h", false)
+	assert.Equal(t, AmbiguousFeedError{}, err)
+
+	names, rest, err = b.selectFeeds(2, "giveaway or score > 10", false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{DefaultFeedName}, names)
+	assert.Equal(t, "giveaway or score > 10", rest)
+
+	// an argument alone isn't taken for the feed of the same name
+	names, rest, err = b.selectFeeds(2, "default", false, "default")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{DefaultFeedName}, names)
+	assert.Equal(t, "default", rest)
+
+	_, _, err = b.selectFeeds(1, "Default", false, "default")
+	assert.Equal(t, AmbiguousFeedError{}, err)
+
+	names, rest, err = b.selectFeeds(1, "default default", false, "default")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{DefaultFeedName}, names)
+	assert.Equal(t, "default", rest)
+}
+
+func TestParseSubscribe(t *testing.T) {
+	cases := []struct {
+		payload, name, subreddits string
+	}{
+		{"", DefaultFeedName, ""},
+		{"r/mechmarket", "mechmarket", "r/mechmarket"},
+		{"Mechanical_Keyboards", "mechanical-keyboards", "Mechanical_Keyboards"},
+		{"trades mechmarket, keyboards", "trades", "mechmarket keyboards"},
+	}
+	for _, c := range cases {
+		name, subreddits, err := parseSubscribe(c.payload)
+		assert.Nil(t, err, c.payload)
+		assert.Equal(t, c.name, name, c.payload)
+		assert.Equal(t, c.subreddits, subreddits, c.payload)
+	}
+
+	_, _, err := parseSubscribe("my_feed mechmarket")
+	assert.NotNil(t, err)
+	_, _, err = parseSubscribe("off mechmarket")
+	assert.NotNil(t, err)
+}
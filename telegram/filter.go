@@ -0,0 +1,114 @@
+package telegram
+
+import (
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/filter"
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	telegram "gopkg.in/tucnak/telebot.v2"
+)
+
+// subscriptionFilter returns the filter expression of the subscription `name`
+// of chatID, isGiveaway if it has none
+func (b *TelegramNotifier) subscriptionFilter(chatID int64, name string) (func(*reddit.Post) bool, error) {
+	sub, err := b.subscription(chatID, name)
+	switch err.(type) {
+	case nil:
+	case KeyNotFoundError:
+		return b.isGiveaway, nil
+	default:
+		return nil, err
+	}
+
+	if len(sub.Filter) == 0 {
+		return b.isGiveaway, nil
+	}
+
+	f, err := filter.Parse(sub.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	env := filter.Env{IsGiveaway: b.isGiveaway, Now: time.Now()}
+	return func(post *reddit.Post) bool {
+		return f.Match(post, env)
+	}, nil
+}
+
+// replyFilter sets the filter expression of a feed of the chat to the payload
+// of `m` ("[feed] <expression>"), or shows the current filter without
+// expression.
+func (b *TelegramNotifier) replyFilter(m *telegram.Message) error {
+	names, payload, err := b.selectFeeds(m.Chat.ID, m.Payload, false, "off")
+	if err != nil {
+		return b.replyFeedError(m, err)
+	}
+	name := names[0]
+
+	if len(payload) == 0 {
+		sub, err := b.subscription(m.Chat.ID, name)
+		switch err.(type) {
+		case nil:
+		case KeyNotFoundError:
+			_, err = b.Send(m.Sender, "You are not subscribed to any feed.")
+			return err
+		default:
+			b.Send(m.Sender, "Unable to read your feed, see logs for detail.")
+			return err
+		}
+
+		message := fmt.Sprintf("No filter%s, only the giveaways are sent.\n", feedLabelHTML(name)) +
+			"Set one with e.g. <code>/filter giveaway or (title contains &quot;GMK&quot; and flair = Photos)</code>, reset it with <code>/filter off</code>."
+		if len(sub.Filter) > 0 {
+			message = fmt.Sprintf(
+				"Filter%s: <code>%s</code>\nReset it with <code>/filter off</code>.",
+				feedLabelHTML(name), html.EscapeString(sub.Filter),
+			)
+		}
+		_, err = b.Send(m.Sender, message, "HTML")
+		return err
+	}
+
+	expr := ""
+	if payload != "off" {
+		f, err := filter.Parse(payload)
+		if syntaxErr, ok := err.(*filter.SyntaxError); ok {
+			_, err = b.Send(m.Sender, fmt.Sprintf(
+				"Invalid filter: %s\n<pre>%s</pre>\n"+
+					"Predicates: giveaway, title/selftext/flair/author (= != contains ~ !~), score and age (= != &lt; &lt;= &gt; &gt;=), combined with not, and, or and parentheses.",
+				html.EscapeString(syntaxErr.Error()), html.EscapeString(syntaxErr.Pointer()),
+			), "HTML")
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		expr = f.String()
+	}
+
+	err = b.updateSubscription(m.Chat.ID, name, func(sub *subscription) error {
+		sub.Filter = expr
+		return nil
+	})
+	switch err.(type) {
+	case nil:
+	case KeyNotFoundError:
+		_, err = b.Send(m.Sender, "You are not subscribed to any feed.")
+		return err
+	default:
+		b.Send(m.Sender, "Unable to save the filter, see logs for detail.")
+		return err
+	}
+
+	message := fmt.Sprintf("Filter removed%s, only the giveaways will be sent.", feedLabelHTML(name))
+	if len(expr) > 0 {
+		message = fmt.Sprintf(
+			"Only the posts%s matching <code>%s</code> will be sent.",
+			feedLabelHTML(name), html.EscapeString(expr),
+		)
+	}
+	_, err = b.Send(m.Sender, message, "HTML")
+	return err
+}
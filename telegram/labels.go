@@ -0,0 +1,325 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/giveaway"
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	bolt "go.etcd.io/bbolt"
+	telegram "gopkg.in/tucnak/telebot.v2"
+)
+
+// the labels bucket maps the FullID of each notified post to its labelRecord.
+// The labels are global on purpose: they train the model shared by all the
+// chats, so a post has a single label, the last one given by any chat.
+const labelsBucketName = "labels-bucket"
+
+// labelRecord is a post sent to a chat, and the label given by the users
+type labelRecord struct {
+	giveaway.Example
+	Sent time.Time `json:"sent"`
+	// Labelled is false until a user labels the post
+	Labelled bool `json:"labelled,omitempty"`
+}
+
+// labelButton is the endpoint of the 👍/👎 buttons of the notifications, the
+// data of the buttons is "<1 for a giveaway, 0 otherwise>|<FullID>"
+var labelButton = telegram.InlineButton{Unique: "label"}
+
+// labelKeyboard returns the 👍/👎 buttons of the notification of the post
+// `fullID`
+func labelKeyboard(fullID string) *telegram.ReplyMarkup {
+	yes := labelButton.With("1|" + fullID)
+	yes.Text = "👍"
+	no := labelButton.With("0|" + fullID)
+	no.Text = "👎"
+
+	return &telegram.ReplyMarkup{
+		InlineKeyboard: [][]telegram.InlineButton{{*yes, *no}},
+	}
+}
+
+// labelsPruneInterval is the period at which the scheduler drops the posts
+// which were not labelled within SeenTTL
+const labelsPruneInterval = time.Hour
+
+// maxMissesLabelled is the number of rejected posts offered for labelling
+// after the output of /peek and /poll
+const maxMissesLabelled = 10
+
+// recordCandidates stores the posts so that they can be labelled later on
+func (b *TelegramNotifier) recordCandidates(posts []*reddit.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	now := time.Now()
+
+	return b.db.Update(func(t *bolt.Tx) error {
+		bucket := t.Bucket([]byte(labelsBucketName))
+
+		for _, post := range posts {
+			// keep the label if the post was sent before
+			if bucket.Get([]byte(post.FullID)) != nil {
+				continue
+			}
+
+			data, err := json.Marshal(&labelRecord{
+				Example: giveaway.Example{
+					Title: post.Title,
+					Flair: post.Flair,
+					Body:  post.Body,
+				},
+				Sent: now,
+			})
+			if err != nil {
+				return err
+			}
+
+			err = bucket.Put([]byte(post.FullID), data)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// pruneLabels drops the posts which were not labelled within SeenTTL
+func (b *TelegramNotifier) pruneLabels(now time.Time) error {
+	return b.db.Update(func(t *bolt.Tx) error {
+		bucket := t.Bucket([]byte(labelsBucketName))
+
+		var expired [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			record := &labelRecord{}
+			err := json.Unmarshal(v, record)
+			if err != nil {
+				return err
+			}
+			if !record.Labelled && now.Sub(record.Sent) > b.SeenTTL {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			err = bucket.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// missedPosts returns the most recent posts of `posts` which were not sent
+// and that the classifier rejected, at most maxMissesLabelled
+func (b *TelegramNotifier) missedPosts(posts, sent []*reddit.Post) []*reddit.Post {
+	wasSent := make(map[string]bool)
+	for _, post := range sent {
+		wasSent[post.FullID] = true
+	}
+
+	var missed []*reddit.Post
+	for _, post := range posts {
+		if len(missed) == maxMissesLabelled {
+			break
+		}
+		if !wasSent[post.FullID] && !b.isGiveaway(post) {
+			missed = append(missed, post)
+		}
+	}
+	return missed
+}
+
+// missesKeyboard returns the numbered 👍/👎 buttons of the posts of
+// missesMessage
+func missesKeyboard(posts []*reddit.Post) *telegram.ReplyMarkup {
+	var rows [][]telegram.InlineButton
+	for i, post := range posts {
+		yes := labelButton.With("1|" + post.FullID)
+		yes.Text = fmt.Sprintf("%d 👍", i+1)
+		no := labelButton.With("0|" + post.FullID)
+		no.Text = fmt.Sprintf("%d 👎", i+1)
+		rows = append(rows, []telegram.InlineButton{*yes, *no})
+	}
+	return &telegram.ReplyMarkup{InlineKeyboard: rows}
+}
+
+// missesMessage lists the posts offered for labelling
+func missesMessage(posts []*reddit.Post) string {
+	lines := []string{"Was one of these a giveaway? Labelling the posts that were not sent helps the classifier too:"}
+	for i, post := range posts {
+		lines = append(lines, fmt.Sprintf("%d. %s", i+1, post.Title))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// offerLabels sends to `to` the posts of `posts` which weren't sent because
+// the classifier rejected them, with 👍/👎 buttons: the labels of the posts
+// it missed are as useful as the ones of the posts it sent.
+func (b *TelegramNotifier) offerLabels(to telegram.Recipient, posts, sent []*reddit.Post) error {
+	missed := b.missedPosts(posts, sent)
+	if len(missed) == 0 {
+		return nil
+	}
+
+	err := b.recordCandidates(missed)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.Send(to, missesMessage(missed), missesKeyboard(missed))
+	return err
+}
+
+// setLabel labels the post `fullID`, it must have been recorded as a
+// candidate
+func (b *TelegramNotifier) setLabel(fullID string, isGiveaway bool) error {
+	return b.db.Update(func(t *bolt.Tx) error {
+		bucket := t.Bucket([]byte(labelsBucketName))
+
+		data := bucket.Get([]byte(fullID))
+		if data == nil {
+			return KeyNotFoundError{}
+		}
+
+		record := &labelRecord{}
+		err := json.Unmarshal(data, record)
+		if err != nil {
+			return err
+		}
+
+		record.Giveaway = isGiveaway
+		record.Labelled = true
+
+		data, err = json.Marshal(record)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(fullID), data)
+	})
+}
+
+// labelledExamples returns the posts labelled in `db`
+func labelledExamples(db *bolt.DB) ([]giveaway.Example, error) {
+	var examples []giveaway.Example
+
+	err := db.View(func(t *bolt.Tx) error {
+		bucket := t.Bucket([]byte(labelsBucketName))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			record := &labelRecord{}
+			err := json.Unmarshal(v, record)
+			if err != nil {
+				return err
+			}
+			if record.Labelled {
+				examples = append(examples, record.Example)
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return examples, nil
+}
+
+// LoadLabels returns the posts labelled by the users of the bot in the
+// database file DBPath. The database must not be in use by a running bot.
+func LoadLabels(DBPath string) ([]giveaway.Example, error) {
+	// a read-only database is not created if missing
+	if _, err := os.Stat(DBPath); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(DBPath, 0666, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return labelledExamples(db)
+}
+
+// train trains a model from the labelled posts and mixes it into the
+// classifier of the bot
+func (b *TelegramNotifier) train() error {
+	examples, err := labelledExamples(b.db)
+	if err != nil {
+		return err
+	}
+
+	mixed := b.Classifier.WithModel(giveaway.TrainNaiveBayes(examples))
+
+	b.classifierMutex.Lock()
+	b.classifier = mixed
+	b.classifierMutex.Unlock()
+
+	return nil
+}
+
+// retrain trains the model again if labels were given since the last
+// training, it is called by the scheduler rather than for each label
+func (b *TelegramNotifier) retrain() error {
+	b.classifierMutex.Lock()
+	changed := b.labelsChanged
+	b.labelsChanged = false
+	b.classifierMutex.Unlock()
+
+	if !changed {
+		return nil
+	}
+
+	err := b.train()
+	if err != nil {
+		// try again on the next call
+		b.classifierMutex.Lock()
+		b.labelsChanged = true
+		b.classifierMutex.Unlock()
+	}
+	return err
+}
+
+// replyLabel stores the label given by a 👍/👎 button, the model is retrained
+// by the scheduler
+func (b *TelegramNotifier) replyLabel(c *telegram.Callback) error {
+	parts := strings.SplitN(c.Data, "|", 2)
+	if len(parts) != 2 || (parts[0] != "0" && parts[0] != "1") {
+		return fmt.Errorf("invalid label %q", c.Data)
+	}
+	isGiveaway := parts[0] == "1"
+
+	err := b.setLabel(parts[1], isGiveaway)
+	switch err.(type) {
+	case nil:
+	case KeyNotFoundError:
+		return b.Respond(c, &telegram.CallbackResponse{Text: "This post is too old to be labelled."})
+	default:
+		b.Respond(c, &telegram.CallbackResponse{Text: "Unable to save the label, see logs for detail."})
+		return err
+	}
+
+	b.classifierMutex.Lock()
+	b.labelsChanged = true
+	b.classifierMutex.Unlock()
+
+	text := "Thanks, noted as not a giveaway."
+	if isGiveaway {
+		text = "Thanks, noted as a giveaway."
+	}
+	return b.Respond(c, &telegram.CallbackResponse{Text: text})
+}
@@ -0,0 +1,104 @@
+package telegram
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabels(t *testing.T) {
+	b := getTestNotifier(t)
+	db := b.db
+	path := db.Path()
+
+	post := &reddit.Post{}
+	post.FullID = "t3_abc"
+	post.Title = "Giveaway winners"
+	assert.Nil(t, b.recordCandidates([]*reddit.Post{post}))
+
+	// unlabelled posts are not examples
+	examples, err := labelledExamples(db)
+	assert.Nil(t, err)
+	assert.Empty(t, examples)
+
+	assert.Nil(t, b.setLabel("t3_abc", false))
+	assert.Equal(t, KeyNotFoundError{}, b.setLabel("t3_unknown", true))
+
+	// sending the post again keeps its label
+	assert.Nil(t, b.recordCandidates([]*reddit.Post{post}))
+	assert.Nil(t, b.train())
+	assert.Nil(t, db.Close())
+
+	examples, err = LoadLabels(path)
+	assert.Nil(t, err)
+	assert.Len(t, examples, 1)
+	assert.Equal(t, "Giveaway winners", examples[0].Title)
+	assert.False(t, examples[0].Giveaway)
+
+	_, err = LoadLabels(filepath.Join(t.TempDir(), "missing.db"))
+	assert.NotNil(t, err)
+}
+
+func TestPruneLabels(t *testing.T) {
+	b := getTestNotifier(t)
+
+	for _, id := range []string{"t3_a", "t3_b", "t3_c"} {
+		post := &reddit.Post{}
+		post.FullID = id
+		assert.Nil(t, b.recordCandidates([]*reddit.Post{post}))
+	}
+	assert.Nil(t, b.setLabel("t3_a", true))
+
+	// the recent posts are kept
+	assert.Nil(t, b.pruneLabels(time.Now()))
+	assert.Nil(t, b.setLabel("t3_b", false))
+
+	// only the unlabelled posts expire
+	assert.Nil(t, b.pruneLabels(time.Now().Add(b.SeenTTL+time.Hour)))
+	assert.Equal(t, KeyNotFoundError{}, b.setLabel("t3_c", true))
+	examples, err := labelledExamples(b.db)
+	assert.Nil(t, err)
+	assert.Len(t, examples, 2)
+}
+
+func TestMissedPosts(t *testing.T) {
+	b := getTestNotifier(t)
+	b.classifier = b.Classifier
+
+	var posts []*reddit.Post
+	for i, title := range []string{"Keycaps [Giveaway]", "My first build", "Desk mat", "Free keycaps"} {
+		post := &reddit.Post{}
+		post.FullID = fmt.Sprintf("t3_%d", i)
+		post.Title = title
+		posts = append(posts, post)
+	}
+
+	// the giveaways and the posts sent aren't offered
+	missed := b.missedPosts(posts, posts[2:3])
+	assert.Len(t, missed, 1)
+	assert.Equal(t, "t3_1", missed[0].FullID)
+
+	assert.Equal(t, "Was one of these a giveaway? Labelling the posts that were not sent helps the classifier too:\n1. My first build", missesMessage(missed))
+	keyboard := missesKeyboard(missed).InlineKeyboard
+	assert.Len(t, keyboard, 1)
+	assert.Equal(t, "1 👍", keyboard[0][0].Text)
+	assert.Equal(t, "1|t3_1", keyboard[0][0].Data)
+	assert.Equal(t, "0|t3_1", keyboard[0][1].Data)
+}
+
+func TestRetrain(t *testing.T) {
+	b := getTestNotifier(t)
+
+	// nothing to do without new labels
+	assert.Nil(t, b.retrain())
+	assert.Nil(t, b.classifier)
+
+	b.labelsChanged = true
+	assert.Nil(t, b.retrain())
+	assert.NotNil(t, b.classifier)
+	assert.False(t, b.labelsChanged)
+}
@@ -0,0 +1,126 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	"github.com/maxime915/mk-giveaway-notifier/sink"
+	bolt "go.etcd.io/bbolt"
+	telegram "gopkg.in/tucnak/telebot.v2"
+)
+
+// MaxPollWindow is the largest window accepted by /poll : older posts would
+// require too many requests to the reddit API.
+const MaxPollWindow = 3 * 24 * time.Hour
+
+// typingPeriod is the period at which the "typing" status is renewed, Telegram
+// displays it for 5 seconds at most.
+const typingPeriod = 4 * time.Second
+
+// subscription returns the subscription `name` of chatID without modifying it
+func (b *TelegramNotifier) subscription(chatID int64, name string) (*subscription, error) {
+	var sub *subscription
+
+	err := b.db.View(func(t *bolt.Tx) error {
+		bucket := t.Bucket([]byte(bucketName))
+
+		data := bucket.Get(feedKey(chatID, name))
+		if data == nil {
+			return KeyNotFoundError{}
+		}
+
+		return json.Unmarshal(data, &sub)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// keepTyping shows the "typing" status to `to` until the returned function
+// is called.
+func (b *TelegramNotifier) keepTyping(to telegram.Recipient) func() {
+	stop := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(typingPeriod)
+		defer ticker.Stop()
+
+		for {
+			b.Notify(to, telegram.Typing)
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() { close(stop) }
+}
+
+// replyPoll sends to the Sender of `m` all giveaways posted in the last `window`
+// on the feed `name` of the chat. The anchor of the feed is not modified.
+func (b *TelegramNotifier) replyPoll(m *telegram.Message, name string, window time.Duration) error {
+	sub, err := b.subscription(m.Chat.ID, name)
+	switch err.(type) {
+	case nil:
+	case KeyNotFoundError:
+		_, err = b.Send(m.Sender, "You are not subscribed to any feed.")
+		return err
+	default:
+		b.Send(m.Sender, "Unable to read your feed, see logs for detail.")
+		return err
+	}
+
+	ctx, cancel := b.operationContext()
+	defer cancel()
+
+	stopTyping := b.keepTyping(m.Sender)
+	posts, err := b.redditBot.PollContext(ctx, &sub.Feed, window)
+	stopTyping()
+
+	if reqErr, ok := err.(*reddit.RequestError); ok {
+		b.Send(m.Sender, describeRequestError(reqErr))
+		return err
+	}
+	if err != nil {
+		b.Send(m.Sender, fmt.Sprintf("error while polling feed: %s", err.Error()))
+		return err
+	}
+
+	filter, err := b.chatFilter(m.Chat.ID, name)
+	if err != nil {
+		b.Send(m.Sender, "Unable to read the settings, see logs for detail.")
+		return err
+	}
+
+	sent, err := b.sendPosts([]sink.Sink{chatSink{b, m.Sender}}, posts, filter)
+	if err != nil {
+		b.Send(m.Sender, "Error encountered while trying to send results")
+		return err
+	}
+
+	if len(posts) == 0 {
+		_, err = b.Send(m.Sender, fmt.Sprintf("No post found in the last %v.", window))
+		return err
+	}
+
+	_, err = b.Send(m.Sender, fmt.Sprintf(
+		"Scanned %d posts from *%s* to *%s*, %d of them were giveaways.",
+		len(posts),
+		posts[len(posts)-1].Created.Time.Local().Format(time.Stamp),
+		posts[0].Created.Time.Local().Format(time.Stamp),
+		len(sent),
+	), "Markdown")
+	if err != nil {
+		return err
+	}
+
+	return b.offerLabels(m.Sender, posts, sent)
+}
@@ -0,0 +1,73 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoll(t *testing.T) {
+	now := time.Now()
+	posts := newTestPosts()
+	for _, post := range posts {
+		post.Created.Time = post.Created.Time.Add(-2 * time.Hour)
+	}
+	posts = append(posts,
+		newTestGiveaway("t3_a", "[Giveaway] GMK Olivia", now.Add(-3*time.Hour)),
+		newTestGiveaway("t3_b", "My first build", now.Add(-4*time.Hour)),
+		newTestGiveaway("t3_c", "[Giveaway] Desk mat", now.Add(-30*time.Hour)),
+	)
+
+	api, b, stop := launchWithFetcher(t, reddit.NewFakeBot(posts), nil)
+	defer stop()
+
+	api.send("/poll 1h")
+	assert.Equal(t, "You are not subscribed to any feed.", api.receive(t))
+
+	api.send("/subscribe")
+	api.receive(t)
+	before, err := b.subscription(7, DefaultFeedName)
+	assert.Nil(t, err)
+
+	usage := "/poll requires a positive duration up to 72h0m0s (e.g. 6h, 2d)"
+	cases := []struct {
+		command string
+		// prefixes of the expected replies
+		replies []string
+	}{
+		{"/poll", []string{usage}},
+		{"/poll soon", []string{usage}},
+		{"/poll 0", []string{usage}},
+		{"/poll -1h", []string{usage}},
+		{"/poll 4d", []string{usage}},
+		{"/poll 1h", []string{"No post found in the last 1h0m0s."}},
+		{"/poll 5h", []string{
+			"[Giveaway] GMK Olivia",
+			"Scanned 12 posts from ",
+			"Was one of these a giveaway?",
+		}},
+		{"/poll 2d", []string{
+			"[Giveaway] GMK Olivia",
+			"[Giveaway] Desk mat",
+			"Scanned 13 posts from ",
+			"Was one of these a giveaway?",
+		}},
+	}
+
+	for _, c := range cases {
+		api.send(c.command)
+		for _, prefix := range c.replies {
+			reply := api.receive(t)
+			assert.True(t, strings.HasPrefix(reply, prefix), "%s: %q doesn't start with %q", c.command, reply, prefix)
+		}
+	}
+
+	// nothing else is sent and the anchor of the feed isn't modified
+	assert.Empty(t, api.pending(t))
+	after, err := b.subscription(7, DefaultFeedName)
+	assert.Nil(t, err)
+	assert.Equal(t, before.Anchor, after.Anchor)
+}
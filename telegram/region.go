@@ -0,0 +1,90 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/maxime915/mk-giveaway-notifier/giveaway"
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	telegram "gopkg.in/tucnak/telebot.v2"
+)
+
+// eligibility returns the regions `post` is open to
+func eligibility(post *reddit.Post) giveaway.Eligibility {
+	return giveaway.ParseEligibility(post.Title, post.Flair, post.Body)
+}
+
+// chatFilter returns the filter of the posts of the feed `name` sent to
+// chatID: the posts selected by the filter of the feed (the giveaways by
+// default) that the chat can enter given its region.
+func (b *TelegramNotifier) chatFilter(chatID int64, name string) (func(*reddit.Post) bool, error) {
+	settings, err := b.settings(chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	selected, err := b.subscriptionFilter(chatID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(settings.Region) == 0 {
+		return selected, nil
+	}
+
+	return func(post *reddit.Post) bool {
+		return selected(post) && eligibility(post).Allows(settings.Region)
+	}, nil
+}
+
+// replyRegion sets the region of the chat to the payload of `m`, or shows the
+// current region without payload.
+func (b *TelegramNotifier) replyRegion(m *telegram.Message) error {
+	names := make([]string, len(giveaway.Regions))
+	for i, region := range giveaway.Regions {
+		names[i] = string(region)
+	}
+	usage := fmt.Sprintf("/region requires one of %s or 'off'", strings.Join(names, ", "))
+
+	payload := strings.ToLower(strings.TrimSpace(m.Payload))
+	if len(payload) == 0 {
+		settings, err := b.settings(m.Chat.ID)
+		if err != nil {
+			b.Send(m.Sender, "Unable to read the settings, see logs for detail.")
+			return err
+		}
+
+		message := "No region set, all giveaways are sent.\n" + usage
+		if len(settings.Region) > 0 {
+			message = fmt.Sprintf("Region is %s.\n%s", settings.Region, usage)
+		}
+		_, err = b.Send(m.Sender, message)
+		return err
+	}
+
+	var region giveaway.Region
+	if payload != "off" {
+		var ok bool
+		region, ok = giveaway.ParseRegion(payload)
+		if !ok {
+			_, err := b.Send(m.Sender, usage)
+			return err
+		}
+	}
+
+	err := b.updateSettings(m.Chat.ID, func(settings *chatSettings) error {
+		settings.Region = region
+		return nil
+	})
+	if err != nil {
+		b.Send(m.Sender, "Unable to save the setting, see logs for detail.")
+		return err
+	}
+
+	message := "Region filter disabled, all giveaways will be sent."
+	if len(region) > 0 {
+		message = fmt.Sprintf("Only the giveaways open to %s (or without any region) will be sent.", region)
+	}
+	_, err = b.Send(m.Sender, message)
+	return err
+}
@@ -0,0 +1,93 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/giveaway"
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatFilter(t *testing.T) {
+	b := getTestNotifier(t)
+	b.redditBot = newTestFakeBot()
+	joinTestFeed(t, b, 0)
+
+	regions := []giveaway.Region{"", giveaway.US, giveaway.EU, giveaway.Other}
+	cases := []struct {
+		title string
+		// sent[i] tells if the post is sent with regions[i]
+		sent []bool
+	}{
+		{"[Giveaway] GMK keycaps [US only]", []bool{true, true, false, false}},
+		{"[Giveaway] Deskmat, worldwide", []bool{true, true, true, true}},
+		{"[Giveaway] Switches (WW except EU)", []bool{true, true, false, true}},
+		{"[Giveaway] Keyboard", []bool{true, true, true, true}},
+		{"My first build, US", []bool{false, false, false, false}},
+	}
+
+	for i, region := range regions {
+		err := b.updateSettings(0, func(settings *chatSettings) error {
+			settings.Region = region
+			return nil
+		})
+		assert.Nil(t, err)
+
+		filter, err := b.chatFilter(0, DefaultFeedName)
+		assert.Nil(t, err)
+
+		for _, c := range cases {
+			post := newTestGiveaway("t3_a", c.title, time.Now())
+			assert.Equal(t, c.sent[i], filter(post), "%q with region %q", c.title, region)
+		}
+	}
+}
+
+func TestRegion(t *testing.T) {
+	// the posts are published once the region is set
+	published := time.Now().Add(500 * time.Millisecond)
+	fetcher := reddit.NewFakeBot(append(newTestPosts(),
+		newTestGiveaway("t3_a", "[Giveaway] GMK keycaps [US only]", published),
+		newTestGiveaway("t3_b", "[Giveaway] Deskmat (EU only)", published),
+		newTestGiveaway("t3_c", "[Giveaway] Keyboard", published),
+	))
+	api, b, stop := launchWithFetcher(t, fetcher, func(b *TelegramNotifier) { b.CacheTTL = 0 })
+	defer stop()
+
+	api.send("/subscribe")
+	api.receive(t)
+
+	usage := "/region requires one of us, conus, canada, eu, uk, other or 'off'"
+	cases := []struct {
+		command string
+		reply   string
+	}{
+		{"/region", "No region set, all giveaways are sent.\n" + usage},
+		{"/region mars", usage},
+		{"/region EU", "Only the giveaways open to eu (or without any region) will be sent."},
+		{"/region", "Region is eu.\n" + usage},
+		{"/region off", "Region filter disabled, all giveaways will be sent."},
+		{"/region", "No region set, all giveaways are sent.\n" + usage},
+		{"/region us", "Only the giveaways open to us (or without any region) will be sent."},
+	}
+
+	for _, c := range cases {
+		api.send(c.command)
+		assert.Equal(t, c.reply, api.receive(t), c.command)
+	}
+
+	settings, err := b.settings(7)
+	assert.Nil(t, err)
+	assert.Equal(t, giveaway.US, settings.Region)
+
+	// the giveaways restricted to the EU are not sent
+	time.Sleep(time.Until(published))
+	assert.Nil(t, b.pushUpdate(7, DefaultFeedName))
+	messages := api.pending(t)
+	assert.Len(t, messages, 2)
+	for i, title := range []string{"[Giveaway] GMK keycaps [US only]", "[Giveaway] Keyboard"} {
+		assert.True(t, strings.HasPrefix(messages[i], title), messages[i])
+	}
+}
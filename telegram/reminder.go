@@ -0,0 +1,288 @@
+package telegram
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/giveaway"
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	bolt "go.etcd.io/bbolt"
+	telegram "gopkg.in/tucnak/telebot.v2"
+)
+
+// the open bucket holds a bucket per chat, mapping the FullID of the
+// giveaways sent to the chat to their openGiveaway
+const openBucketName = "open-bucket"
+
+// DefaultReminder is the default delay before the deadline of a giveaway at
+// which a reminder is sent
+const DefaultReminder = 2 * time.Hour
+
+// openGiveaway is a giveaway sent to a chat, with its deadline if found
+type openGiveaway struct {
+	FullID    string     `json:"name"`
+	Title     string     `json:"title"`
+	Author    string     `json:"author"`
+	Permalink string     `json:"permalink"`
+	Created   time.Time  `json:"created"`
+	Deadline  *time.Time `json:"deadline,omitempty"`
+	Reminded  bool       `json:"reminded,omitempty"`
+}
+
+// deadline returns the deadline of a post if it states one
+func deadline(post *reddit.Post) (time.Time, bool) {
+	return giveaway.ParseDeadline(post.Title+"\n"+post.Body, post.Created.Time)
+}
+
+// recordOpen stores the giveaways sent to chatID with their deadline
+func (b *TelegramNotifier) recordOpen(chatID int64, posts []*reddit.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	return b.db.Update(func(t *bolt.Tx) error {
+		bucket, err := t.Bucket([]byte(openBucketName)).CreateBucketIfNotExists(chatKey(chatID))
+		if err != nil {
+			return err
+		}
+
+		for _, post := range posts {
+			open := &openGiveaway{
+				FullID:    post.FullID,
+				Title:     post.Title,
+				Author:    post.Author,
+				Permalink: post.Permalink,
+				Created:   post.Created.Time,
+			}
+			if end, ok := deadline(post); ok {
+				open.Deadline = &end
+			}
+
+			data, err := json.Marshal(open)
+			if err != nil {
+				return err
+			}
+
+			err = bucket.Put([]byte(post.FullID), data)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// openGiveaways returns the giveaways of chatID which are not over, ordered
+// by deadline (giveaways without deadline last, newest first)
+func (b *TelegramNotifier) openGiveaways(chatID int64) ([]*openGiveaway, error) {
+	var giveaways []*openGiveaway
+	now := time.Now()
+
+	err := b.db.View(func(t *bolt.Tx) error {
+		bucket := t.Bucket([]byte(openBucketName)).Bucket(chatKey(chatID))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var open *openGiveaway
+			err := json.Unmarshal(v, &open)
+			if err != nil {
+				return err
+			}
+
+			if open.Deadline == nil || open.Deadline.After(now) {
+				giveaways = append(giveaways, open)
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(giveaways, func(i, j int) bool {
+		a, b := giveaways[i], giveaways[j]
+		switch {
+		case a.Deadline != nil && b.Deadline != nil:
+			return a.Deadline.Before(*b.Deadline)
+		case a.Deadline == nil && b.Deadline == nil:
+			return a.Created.After(b.Created)
+		default:
+			return a.Deadline != nil
+		}
+	})
+
+	return giveaways, nil
+}
+
+// deleteOpen removes the open giveaways of chatID in the transaction, if any
+func deleteOpen(t *bolt.Tx, chatID int64) error {
+	err := t.Bucket([]byte(openBucketName)).DeleteBucket(chatKey(chatID))
+	if err == bolt.ErrBucketNotFound {
+		return nil
+	}
+	return err
+}
+
+// remind sends a reminder for each giveaway whose deadline is closer than the
+// reminder delay of its chat, and forgets the giveaways that are over
+func (b *TelegramNotifier) remind(now time.Time) error {
+	var chats []int64
+	err := b.db.View(func(t *bolt.Tx) error {
+		return t.Bucket([]byte(openBucketName)).ForEach(func(k, _ []byte) error {
+			chats = append(chats, int64(binary.BigEndian.Uint64(k)))
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, chatID := range chats {
+		due, err := b.dueReminders(chatID, now)
+		if err != nil {
+			return err
+		}
+
+		// send outside of the transaction
+		for _, open := range due {
+			_, err := b.Send(telegram.ChatID(chatID), fmt.Sprintf(
+				"Reminder: ends in %s\n%s by u/%s\nold.reddit.com%s",
+				formatDelay(open.Deadline.Sub(now)),
+				open.Title,
+				open.Author,
+				open.Permalink,
+			))
+			if err != nil {
+				log.Printf("unable to send a reminder to chat %d: %v\n", chatID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// dueReminders returns the giveaways of chatID whose reminder is due at `now`
+// and marks them as reminded. The giveaways that are over are forgotten.
+func (b *TelegramNotifier) dueReminders(chatID int64, now time.Time) ([]*openGiveaway, error) {
+	settings, err := b.settings(chatID)
+	if err != nil {
+		return nil, err
+	}
+	delay := settings.Reminder
+	if delay == 0 {
+		delay = DefaultReminder
+	}
+
+	var due []*openGiveaway
+	err = b.db.Update(func(t *bolt.Tx) error {
+		due = nil
+		bucket := t.Bucket([]byte(openBucketName)).Bucket(chatKey(chatID))
+		if bucket == nil {
+			return nil
+		}
+
+		// the bucket is modified once the iteration is over, as modifying it
+		// under a cursor makes it skip entries
+		var over [][]byte
+		reminded := make(map[string][]byte)
+		err := bucket.ForEach(func(k, v []byte) error {
+			var open *openGiveaway
+			err := json.Unmarshal(v, &open)
+			if err != nil {
+				return err
+			}
+
+			// over, or too old to know
+			if (open.Deadline != nil && !open.Deadline.After(now)) ||
+				(open.Deadline == nil && now.Sub(open.Created) > b.SeenTTL) {
+				over = append(over, append([]byte(nil), k...))
+				return nil
+			}
+
+			if delay < 0 || open.Deadline == nil || open.Reminded || open.Deadline.Sub(now) > delay {
+				return nil
+			}
+
+			open.Reminded = true
+			data, err := json.Marshal(open)
+			if err != nil {
+				return err
+			}
+			reminded[string(k)] = data
+			due = append(due, open)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range over {
+			err = bucket.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		for k, data := range reminded {
+			err = bucket.Put([]byte(k), data)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// formatDelay formats a delay rounded to the minute (e.g. "2h", "1h30m")
+func formatDelay(delay time.Duration) string {
+	delay = delay.Round(time.Minute)
+	hours := int(delay.Hours())
+	minutes := int(delay.Minutes()) % 60
+
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	default:
+		return fmt.Sprintf("%dh%02dm", hours, minutes)
+	}
+}
+
+// replyOpen lists the open giveaways of the chat of `m`, ordered by deadline
+func (b *TelegramNotifier) replyOpen(m *telegram.Message) error {
+	giveaways, err := b.openGiveaways(m.Chat.ID)
+	if err != nil {
+		b.Send(m.Sender, "Unable to read the open giveaways, see logs for detail.")
+		return err
+	}
+
+	if len(giveaways) == 0 {
+		_, err = b.Send(m.Sender, "No open giveaway.")
+		return err
+	}
+
+	message := fmt.Sprintf("%d open giveaway(s):", len(giveaways))
+	for _, open := range giveaways {
+		end := "no deadline found"
+		if open.Deadline != nil {
+			end = "ends " + open.Deadline.Local().Format("Mon Jan 2 15:04")
+		}
+		message += fmt.Sprintf("\n\n%s (%s)\nold.reddit.com%s", open.Title, end, open.Permalink)
+	}
+
+	_, err = b.Send(m.Sender, message)
+	return err
+}
@@ -0,0 +1,95 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	"github.com/stretchr/testify/assert"
+	goreddit "github.com/vartanbeno/go-reddit/v2/reddit"
+	bolt "go.etcd.io/bbolt"
+)
+
+// newTestGiveaway returns a giveaway posted at `created` titled `title`
+func newTestGiveaway(id, title string, created time.Time) *reddit.Post {
+	post := &reddit.Post{}
+	post.FullID = id
+	post.Title = title
+	post.Created = &goreddit.Timestamp{Time: created}
+	return post
+}
+
+func TestDueReminders(t *testing.T) {
+	now := time.Now()
+	b := getTestNotifier(t)
+	assert.Nil(t, b.recordOpen(0, []*reddit.Post{
+		newTestGiveaway("t3_a", "[Giveaway] ends in 2 hours", now.Add(-90*time.Minute)),
+		newTestGiveaway("t3_b", "[Giveaway] ends in 1 hour", now.Add(-2*time.Hour)),
+		newTestGiveaway("t3_c", "[Giveaway] ends in 1 hour", now.Add(-3*time.Hour)),
+		newTestGiveaway("t3_d", "[Giveaway] ends in 1 hour", now),
+		newTestGiveaway("t3_e", "[Giveaway] ends in 3 days", now),
+		newTestGiveaway("t3_f", "[Giveaway] ends in 1 hour", now.Add(-4*time.Hour)),
+	}))
+
+	// the giveaways ending within the default delay are due, once
+	due, err := b.dueReminders(0, now)
+	assert.Nil(t, err)
+	var ids []string
+	for _, open := range due {
+		ids = append(ids, open.FullID)
+	}
+	assert.ElementsMatch(t, []string{"t3_a", "t3_d"}, ids)
+
+	due, err = b.dueReminders(0, now)
+	assert.Nil(t, err)
+	assert.Empty(t, due)
+
+	// and the consecutive giveaways which are over are all forgotten
+	open, err := b.openGiveaways(0)
+	assert.Nil(t, err)
+	assert.Len(t, open, 3)
+	assert.Equal(t, 3, countOpen(t, b, 0))
+
+	// no reminder once disabled
+	assert.Nil(t, b.updateSettings(0, func(settings *chatSettings) error {
+		settings.Reminder = -1
+		return nil
+	}))
+	assert.Nil(t, b.recordOpen(0, []*reddit.Post{newTestGiveaway("t3_g", "[Giveaway] ends in 1 hour", now)}))
+	due, err = b.dueReminders(0, now)
+	assert.Nil(t, err)
+	assert.Empty(t, due)
+}
+
+// countOpen returns the number of giveaways stored for chatID, over or not
+func countOpen(t *testing.T, b *TelegramNotifier, chatID int64) int {
+	count := 0
+	err := b.db.View(func(t *bolt.Tx) error {
+		bucket := t.Bucket([]byte(openBucketName)).Bucket(chatKey(chatID))
+		if bucket != nil {
+			count = bucket.Stats().KeyN
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	return count
+}
+
+func TestRemind(t *testing.T) {
+	api, b, stop := launchWithFakeBotAPI(t)
+	defer stop()
+
+	// wait for the bot to be launched
+	api.send("/ping")
+	api.receive(t)
+
+	now := time.Now()
+	assert.Nil(t, b.recordOpen(7, []*reddit.Post{
+		newTestGiveaway("t3_a", "[Giveaway] GMK Olivia, ends in 1 hour", now),
+	}))
+	assert.Nil(t, b.remind(now))
+
+	reminder := api.receive(t)
+	assert.True(t, strings.HasPrefix(reminder, "Reminder: ends in 1h\n[Giveaway] GMK Olivia"), reminder)
+}
@@ -0,0 +1,192 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	bolt "go.etcd.io/bbolt"
+	telegram "gopkg.in/tucnak/telebot.v2"
+)
+
+// scheduler settings
+const (
+	DefaultPollInterval = 15 * time.Minute
+	DefaultPollJitter   = 2 * time.Minute
+	// MinPollInterval is the smallest interval accepted for a feed
+	MinPollInterval = time.Minute
+	// scheduleTick is the resolution of the scheduler
+	scheduleTick = 30 * time.Second
+)
+
+// schedule periodically updates every stored feed and pushes the giveaways
+// to the chat owning the feed. It returns once the bot is stopped.
+func (b *TelegramNotifier) schedule() {
+	defer b.scheduler.Done()
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	next := make(map[feedID]time.Time)
+	var pruned time.Time
+
+	ticker := time.NewTicker(scheduleTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case now := <-ticker.C:
+			err := b.remind(now)
+			if err != nil {
+				log.Println(err)
+			}
+
+			err = b.retrain()
+			if err != nil {
+				log.Printf("unable to train the classifier: %v\n", err)
+			}
+
+			if now.Sub(pruned) >= labelsPruneInterval {
+				pruned = now
+				err = b.pruneLabels(now)
+				if err != nil {
+					log.Println(err)
+				}
+			}
+
+			intervals, err := b.intervals()
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+
+			// forget about the feeds that were unsubscribed
+			for feed := range next {
+				if _, ok := intervals[feed]; !ok {
+					delete(next, feed)
+				}
+			}
+
+			for feed, interval := range intervals {
+				// stop as soon as possible, there may be many feeds to update
+				select {
+				case <-b.done:
+					return
+				default:
+				}
+
+				due, ok := next[feed]
+				if !ok {
+					// spread the first updates over the jitter
+					next[feed] = now.Add(b.jitter(rng))
+					continue
+				}
+				if now.Before(due) {
+					continue
+				}
+
+				next[feed] = now.Add(b.delay(rng, interval))
+
+				err := b.pushUpdate(feed.chatID, feed.name)
+				if err != nil {
+					log.Printf("scheduled update for feed %s of chat %d failed: %v\n", feed.name, feed.chatID, err)
+				}
+			}
+		}
+	}
+}
+
+// intervals returns the update interval of each stored subscription
+func (b *TelegramNotifier) intervals() (map[feedID]time.Duration, error) {
+	intervals := make(map[feedID]time.Duration)
+
+	err := b.db.View(func(t *bolt.Tx) error {
+		bucket := t.Bucket([]byte(bucketName))
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var sub *subscription
+			err := json.Unmarshal(v, &sub)
+			if err != nil {
+				return err
+			}
+
+			chatID, name := parseFeedKey(k)
+			intervals[feedID{chatID, name}] = sub.Interval
+			return nil
+		})
+	})
+
+	return intervals, err
+}
+
+// jitter returns a random duration in [0, PollJitter)
+func (b *TelegramNotifier) jitter(rng *rand.Rand) time.Duration {
+	if b.PollJitter <= 0 {
+		return 0
+	}
+	return time.Duration(rng.Int63n(int64(b.PollJitter)))
+}
+
+// delay returns the duration to wait before the next update of a feed, using
+// the default interval if `interval` is zero. The jitter is centered on the
+// interval and the result is never below MinPollInterval.
+func (b *TelegramNotifier) delay(rng *rand.Rand, interval time.Duration) time.Duration {
+	if interval == 0 {
+		interval = b.PollInterval
+	}
+
+	delay := interval - b.PollJitter/2 + b.jitter(rng)
+	if delay < MinPollInterval {
+		delay = MinPollInterval
+	}
+	return delay
+}
+
+// pushUpdate updates the feed `name` of chatID and sends the giveaways to the
+// chat. Nothing is sent if there are no new giveaways.
+func (b *TelegramNotifier) pushUpdate(chatID int64, name string) error {
+	ctx, cancel := b.operationContext()
+	defer cancel()
+
+	posts, err := b.fetchFeed(ctx, chatID, name, b.updateShared)
+
+	switch err.(type) {
+	case nil:
+	case KeyNotFoundError:
+		// unsubscribed in the meantime
+		return nil
+	case reddit.EmptyAnchorError:
+		// must be /touch'ed by the user first
+		return nil
+	default:
+		// interrupted by Stop
+		if b.ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	filter, err := b.chatFilter(chatID, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.deliverPosts(chatID, name, telegram.ChatID(chatID), posts, filter)
+	return err
+}
+
+// setInterval sets the update interval of the feed `name` of chatID, zero
+// meaning the default interval.
+func (b *TelegramNotifier) setInterval(chatID int64, name string, interval time.Duration) error {
+	if interval != 0 && interval < MinPollInterval {
+		return fmt.Errorf("interval must be at least %v", MinPollInterval)
+	}
+
+	return b.updateSubscription(chatID, name, func(sub *subscription) error {
+		sub.Interval = interval
+		return nil
+	})
+}
@@ -0,0 +1,109 @@
+package telegram
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelay(t *testing.T) {
+	cases := []struct {
+		name     string
+		interval time.Duration
+		jitter   time.Duration
+		min, max time.Duration
+	}{
+		{"default interval", 0, 2 * time.Minute, 14 * time.Minute, 16 * time.Minute},
+		{"feed interval", time.Hour, 2 * time.Minute, 59 * time.Minute, 61 * time.Minute},
+		{"no jitter", 30 * time.Minute, 0, 30 * time.Minute, 30 * time.Minute},
+		{"at least MinPollInterval", time.Minute, 10 * time.Minute, MinPollInterval, 6 * time.Minute},
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for _, c := range cases {
+		b := newEmptyBot()
+		b.PollJitter = c.jitter
+
+		for i := 0; i < 100; i++ {
+			delay := b.delay(rng, c.interval)
+			assert.True(t, delay >= c.min && delay <= c.max, "%s: %v not in [%v, %v]", c.name, delay, c.min, c.max)
+
+			jitter := b.jitter(rng)
+			assert.True(t, jitter >= 0 && (jitter < c.jitter || c.jitter == 0), "%s: jitter %v", c.name, jitter)
+		}
+	}
+}
+
+func TestIntervals(t *testing.T) {
+	b := getTestNotifier(t)
+	b.redditBot = newTestFakeBot()
+	joinTestFeed(t, b, 0)
+	joinTestFeed(t, b, 1)
+
+	assert.Nil(t, b.setInterval(1, DefaultFeedName, 30*time.Minute))
+	assert.NotNil(t, b.setInterval(1, DefaultFeedName, 30*time.Second))
+	assert.Equal(t, KeyNotFoundError{}, b.setInterval(2, DefaultFeedName, time.Hour))
+
+	intervals, err := b.intervals()
+	assert.Nil(t, err)
+	assert.Equal(t, map[feedID]time.Duration{
+		{0, DefaultFeedName}: 0,
+		{1, DefaultFeedName}: 30 * time.Minute,
+	}, intervals)
+}
+
+func TestScheduleStops(t *testing.T) {
+	b := getTestNotifier(t)
+	b.scheduler.Add(1)
+	go b.schedule()
+
+	stopped := make(chan struct{})
+	go func() {
+		b.Stop()
+		b.scheduler.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("the scheduler didn't stop")
+	}
+}
+
+func TestPushUpdate(t *testing.T) {
+	// the posts are published once the chat subscribed
+	published := time.Now().Add(500 * time.Millisecond)
+	fetcher := reddit.NewFakeBot(append(newTestPosts(),
+		newTestGiveaway("t3_old", "[Giveaway] already published", time.Now().Add(-time.Hour)),
+		newTestGiveaway("t3_a", "[Giveaway] GMK Olivia", published),
+		newTestGiveaway("t3_b", "My first build", published),
+		newTestGiveaway("t3_c", "[Giveaway] Desk mat", published),
+	))
+	api, b, stop := launchWithFetcher(t, fetcher, func(b *TelegramNotifier) { b.CacheTTL = 0 })
+	defer stop()
+
+	api.send("/subscribe")
+	api.receive(t)
+
+	// nothing new
+	assert.Nil(t, b.pushUpdate(7, DefaultFeedName))
+	assert.Empty(t, api.pending(t))
+
+	time.Sleep(time.Until(published))
+	assert.Nil(t, b.pushUpdate(7, DefaultFeedName))
+	messages := api.pending(t)
+	assert.Len(t, messages, 2)
+	for i, title := range []string{"[Giveaway] GMK Olivia", "[Giveaway] Desk mat"} {
+		assert.True(t, strings.HasPrefix(messages[i], title), messages[i])
+	}
+
+	// the giveaways are pushed once, and the unsubscribed feeds are skipped
+	assert.Nil(t, b.pushUpdate(7, DefaultFeedName))
+	assert.Nil(t, b.pushUpdate(7, "trades"))
+	assert.Empty(t, api.pending(t))
+}
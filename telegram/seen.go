@@ -0,0 +1,171 @@
+package telegram
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	bolt "go.etcd.io/bbolt"
+	telegram "gopkg.in/tucnak/telebot.v2"
+)
+
+// the seen bucket holds a bucket per chat, mapping the FullID of the posts
+// sent to the chat to the time they were sent
+const seenBucketName = "seen-bucket"
+
+// DefaultSeenTTL is the default duration for which a sent post is remembered
+const DefaultSeenTTL = 14 * 24 * time.Hour
+
+// seenPosts returns the posts sent to chatID which have not expired, mapped to
+// the time they were sent
+func (b *TelegramNotifier) seenPosts(chatID int64) (map[string]time.Time, error) {
+	seen := make(map[string]time.Time)
+	limit := time.Now().Add(-b.SeenTTL)
+
+	err := b.db.View(func(t *bolt.Tx) error {
+		bucket := t.Bucket([]byte(seenBucketName)).Bucket(chatKey(chatID))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			sent := time.Unix(int64(binary.BigEndian.Uint64(v)), 0)
+			if sent.After(limit) {
+				seen[string(k)] = sent
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return seen, nil
+}
+
+// markSeen records the posts as sent to chatID and forgets the expired ones
+func (b *TelegramNotifier) markSeen(chatID int64, posts []*reddit.Post) error {
+	now := time.Now()
+	limit := now.Add(-b.SeenTTL)
+
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(now.Unix()))
+
+	return b.db.Update(func(t *bolt.Tx) error {
+		bucket, err := t.Bucket([]byte(seenBucketName)).CreateBucketIfNotExists(chatKey(chatID))
+		if err != nil {
+			return err
+		}
+
+		// remove expired entries, once the iteration is over as deleting
+		// under a cursor makes it skip the next entry
+		var expired [][]byte
+		err = bucket.ForEach(func(k, v []byte) error {
+			if time.Unix(int64(binary.BigEndian.Uint64(v)), 0).Before(limit) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			err = bucket.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, post := range posts {
+			err = bucket.Put([]byte(post.FullID), value)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// resetSeen forgets all posts sent to chatID
+func (b *TelegramNotifier) resetSeen(chatID int64) error {
+	return b.db.Update(func(t *bolt.Tx) error {
+		return deleteSeen(t, chatID)
+	})
+}
+
+// deleteSeen removes the seen-set of chatID in the transaction, if any
+func deleteSeen(t *bolt.Tx, chatID int64) error {
+	err := t.Bucket([]byte(seenBucketName)).DeleteBucket(chatKey(chatID))
+	if err == bolt.ErrBucketNotFound {
+		return nil
+	}
+	return err
+}
+
+// deliverPosts is like sendPosts but skips the posts already sent to chatID
+// and uses the sinks of the feed `name`, `to` being the Telegram sink. The
+// delivered posts are remembered as sent.
+func (b *TelegramNotifier) deliverPosts(chatID int64, name string, to telegram.Recipient, posts []*reddit.Post, filter func(*reddit.Post) bool) (int, error) {
+	seen, err := b.seenPosts(chatID)
+	if err != nil {
+		return 0, err
+	}
+
+	sinks, err := b.feedSinks(chatID, name, to)
+	if err != nil {
+		return 0, err
+	}
+
+	unseen := func(post *reddit.Post) bool {
+		_, ok := seen[post.FullID]
+		return !ok && filter(post)
+	}
+
+	sent, sendErr := b.sendPosts(sinks, posts, unseen)
+
+	// record the posts sent before any error
+	err = b.markSeen(chatID, sent)
+	if err == nil {
+		err = b.recordOpen(chatID, sent)
+	}
+	if sendErr != nil {
+		return len(sent), sendErr
+	}
+	return len(sent), err
+}
+
+// maxSeenListed is the maximum number of posts listed by /seen
+const maxSeenListed = 20
+
+// replySeen sends the number of posts remembered for the chat and lists the
+// most recent ones
+func (b *TelegramNotifier) replySeen(m *telegram.Message) error {
+	seen, err := b.seenPosts(m.Chat.ID)
+	if err != nil {
+		b.Send(m.Sender, "Unable to read the sent posts, see logs for detail.")
+		return err
+	}
+
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return seen[ids[i]].After(seen[ids[j]])
+	})
+	if len(ids) > maxSeenListed {
+		ids = ids[:maxSeenListed]
+	}
+
+	message := fmt.Sprintf("%d post(s) sent in the last %v.", len(seen), b.SeenTTL)
+	for _, id := range ids {
+		message += fmt.Sprintf("\n%s at %s", id, seen[id].Local().Format(time.Stamp))
+	}
+
+	_, err = b.Send(m.Sender, message)
+	return err
+}
@@ -0,0 +1,118 @@
+package telegram
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	"github.com/maxime915/mk-giveaway-notifier/sink"
+	"github.com/stretchr/testify/assert"
+	goreddit "github.com/vartanbeno/go-reddit/v2/reddit"
+	bolt "go.etcd.io/bbolt"
+)
+
+// putSeen records the post `id` as sent to chatID at `sent`
+func putSeen(t *testing.T, b *TelegramNotifier, chatID int64, id string, sent time.Time) {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(sent.Unix()))
+	err := b.db.Update(func(t *bolt.Tx) error {
+		bucket, err := t.Bucket([]byte(seenBucketName)).CreateBucketIfNotExists(chatKey(chatID))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(id), value)
+	})
+	assert.Nil(t, err)
+}
+
+// countSeen returns the number of entries stored for chatID, expired or not
+func countSeen(t *testing.T, b *TelegramNotifier, chatID int64) int {
+	count := 0
+	err := b.db.View(func(t *bolt.Tx) error {
+		bucket := t.Bucket([]byte(seenBucketName)).Bucket(chatKey(chatID))
+		if bucket == nil {
+			return nil
+		}
+		count = bucket.Stats().KeyN
+		return nil
+	})
+	assert.Nil(t, err)
+	return count
+}
+
+func TestSeenExpiry(t *testing.T) {
+	b := getTestNotifier(t)
+	b.SeenTTL = time.Hour
+
+	// consecutive expired entries are all removed
+	old := time.Now().Add(-2 * time.Hour)
+	for _, id := range []string{"t3_a", "t3_b", "t3_c", "t3_d", "t3_e"} {
+		putSeen(t, b, 0, id, old)
+	}
+	putSeen(t, b, 0, "t3_f", time.Now())
+
+	seen, err := b.seenPosts(0)
+	assert.Nil(t, err)
+	assert.Len(t, seen, 1)
+	assert.Contains(t, seen, "t3_f")
+
+	post := &reddit.Post{}
+	post.FullID = "t3_g"
+	assert.Nil(t, b.markSeen(0, []*reddit.Post{post}))
+	assert.Equal(t, 2, countSeen(t, b, 0))
+
+	seen, err = b.seenPosts(0)
+	assert.Nil(t, err)
+	assert.Len(t, seen, 2)
+	assert.Contains(t, seen, "t3_g")
+
+	assert.Nil(t, b.resetSeen(0))
+	assert.Equal(t, 0, countSeen(t, b, 0))
+}
+
+func TestDeliverSkipsSeen(t *testing.T) {
+	var delivered int
+	discord := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer discord.Close()
+
+	b := getTestNotifier(t)
+	config, err := sink.Parse(sink.TypeDiscord, discord.URL)
+	assert.Nil(t, err)
+	assert.Nil(t, b.addListeners(0, DefaultFeedName, &subscription{Sinks: []sink.Config{config}}))
+
+	var posts []*reddit.Post
+	for _, id := range []string{"t3_a", "t3_b"} {
+		post := &reddit.Post{}
+		post.FullID = id
+		post.Created = &goreddit.Timestamp{Time: time.Now()}
+		posts = append(posts, post)
+	}
+	all := func(*reddit.Post) bool { return true }
+
+	// a post sent to the chat isn't sent again
+	putSeen(t, b, 0, "t3_a", time.Now())
+	count, err := b.deliverPosts(0, DefaultFeedName, nil, posts, all)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 1, delivered)
+
+	// unless it expired
+	b.SeenTTL = time.Hour
+	putSeen(t, b, 0, "t3_a", time.Now().Add(-2*time.Hour))
+	putSeen(t, b, 0, "t3_b", time.Now().Add(-2*time.Hour))
+	count, err = b.deliverPosts(0, DefaultFeedName, nil, posts, all)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 3, delivered)
+
+	// another chat has its own seen set
+	seen, err := b.seenPosts(1)
+	assert.Nil(t, err)
+	assert.Empty(t, seen)
+}
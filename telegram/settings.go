@@ -0,0 +1,70 @@
+package telegram
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/giveaway"
+	bolt "go.etcd.io/bbolt"
+)
+
+// the settings bucket maps each chat to its chatSettings
+const settingsBucketName = "settings-bucket"
+
+// chatSettings are the preferences of a chat, shared by all its feeds
+type chatSettings struct {
+	// Reminder is the delay before the deadline of a giveaway at which a
+	// reminder is sent. Zero means the default delay, a negative value
+	// disables the reminders.
+	Reminder time.Duration `json:"reminder,omitempty"`
+	// Region is the region of the chat, the giveaways it cannot enter are not
+	// sent. Empty means no region filter.
+	Region giveaway.Region `json:"region,omitempty"`
+}
+
+// settings returns the settings of chatID, the zero value if none were stored
+func (b *TelegramNotifier) settings(chatID int64) (*chatSettings, error) {
+	settings := &chatSettings{}
+
+	err := b.db.View(func(t *bolt.Tx) error {
+		data := t.Bucket([]byte(settingsBucketName)).Get(chatKey(chatID))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, settings)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// updateSettings applies `update` to the settings of chatID and stores them
+func (b *TelegramNotifier) updateSettings(chatID int64, update func(*chatSettings) error) error {
+	return b.db.Update(func(t *bolt.Tx) error {
+		bucket := t.Bucket([]byte(settingsBucketName))
+		key := chatKey(chatID)
+
+		settings := &chatSettings{}
+		if data := bucket.Get(key); data != nil {
+			err := json.Unmarshal(data, settings)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := update(settings)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(settings)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(key, data)
+	})
+}
@@ -0,0 +1,345 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	bolt "go.etcd.io/bbolt"
+)
+
+// the shared bucket maps each set of subreddits to its sharedFeed
+const sharedBucketName = "shared-bucket"
+
+// DefaultCacheTTL is the default duration for which the posts of a shared
+// feed are served without calling reddit
+const DefaultCacheTTL = 5 * time.Minute
+
+// the posts of a shared feed are kept for cacheWindow, up to maxCachedPosts.
+// The subscriptions which fall behind crawl back to their own anchor.
+const (
+	cacheWindow    = 24 * time.Hour
+	maxCachedPosts = 500
+	// touchedPosts is the number of posts returned by a touch
+	touchedPosts = 5
+)
+
+// cachedPost is a post of a shared feed, numbered in the order in which it
+// was fetched
+type cachedPost struct {
+	Seq  uint64       `json:"seq"`
+	Post *reddit.Post `json:"post"`
+}
+
+// sharedFeed is the feed of a set of subreddits shared by all the
+// subscriptions following these subreddits: reddit is called once for all of
+// them, and each subscription keeps the number of the last post it received
+// (its cursor).
+type sharedFeed struct {
+	// Feed holds the canonical anchor of the subreddits
+	Feed reddit.Feed `json:"feed"`
+	// Posts are the recently fetched posts, newest first
+	Posts []cachedPost `json:"posts,omitempty"`
+	// Seq is the number of the last fetched post
+	Seq uint64 `json:"seq"`
+	// Pruned is the highest number of the posts dropped from the cache
+	Pruned  uint64    `json:"pruned,omitempty"`
+	Updated time.Time `json:"updated"`
+	// Version changes each time the feed is stored (see retryOnConflict)
+	Version uint64 `json:"version,omitempty"`
+}
+
+// canonicalSubreddits returns the canonical form of a set of subreddits
+// joined by "+": sorted, lower case and without duplicates
+func canonicalSubreddits(subreddits string) string {
+	var names []string
+	found := make(map[string]bool)
+	for _, name := range strings.Split(strings.ToLower(subreddits), "+") {
+		if len(name) > 0 && !found[name] {
+			found[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, "+")
+}
+
+// add numbers the fetched posts (newest first) and prepends them to the cache,
+// then drops the posts older than cacheWindow.
+func (s *sharedFeed) add(posts []*reddit.Post, now time.Time) {
+	cached := make([]cachedPost, 0, len(posts)+len(s.Posts))
+	for i, post := range posts {
+		// the oldest posts get the lowest numbers
+		cached = append(cached, cachedPost{Seq: s.Seq + uint64(len(posts)-i), Post: post})
+	}
+	s.Seq += uint64(len(posts))
+	cached = append(cached, s.Posts...)
+
+	kept := cached[:0]
+	for _, entry := range cached {
+		if len(kept) == maxCachedPosts || (entry.Post.Created != nil && now.Sub(entry.Post.Created.Time) > cacheWindow) {
+			if entry.Seq > s.Pruned {
+				s.Pruned = entry.Seq
+			}
+			continue
+		}
+		kept = append(kept, entry)
+	}
+
+	s.Posts = kept
+	s.Updated = now
+}
+
+// since returns the cached posts fetched after the post numbered `cursor`,
+// newest first
+func (s *sharedFeed) since(cursor uint64) []*reddit.Post {
+	var posts []*reddit.Post
+	for _, entry := range s.Posts {
+		if entry.Seq > cursor {
+			posts = append(posts, entry.Post)
+		}
+	}
+	return posts
+}
+
+// behind returns true if some of the posts fetched after the post numbered
+// `cursor` were dropped from the cache
+func (s *sharedFeed) behind(cursor uint64) bool {
+	return s.Pruned > cursor
+}
+
+// latest returns the `count` newest cached posts
+func (s *sharedFeed) latest(count int) []*reddit.Post {
+	var posts []*reddit.Post
+	for _, entry := range s.Posts {
+		if len(posts) == count {
+			break
+		}
+		posts = append(posts, entry.Post)
+	}
+	return posts
+}
+
+// refreshShared returns the shared feed of `subreddits`, updated from reddit
+// if its posts are older than CacheTTL or if `force` is true. The feed is
+// created (and touched) if it doesn't exist. A positive anchorSize changes
+// the size of the anchor of the feed.
+// Reddit is called outside of any transaction: the feed is stored only if it
+// wasn't modified in the meantime, otherwise it is read and refreshed again.
+func (b *TelegramNotifier) refreshShared(ctx context.Context, subreddits string, anchorSize int, force bool) (*sharedFeed, error) {
+	key := []byte(canonicalSubreddits(subreddits))
+
+	var shared *sharedFeed
+	err := retryOnConflict(func() error {
+		var stored *sharedFeed
+		err := b.db.View(func(t *bolt.Tx) error {
+			data := t.Bucket([]byte(sharedBucketName)).Get(key)
+			if data == nil {
+				return nil
+			}
+			return json.Unmarshal(data, &stored)
+		})
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var posts []*reddit.Post
+		switch {
+		case stored == nil:
+			shared = &sharedFeed{}
+			shared.Feed.Subreddits = string(key)
+			posts, err = b.redditBot.TouchContext(ctx, &shared.Feed)
+		case force || now.Sub(stored.Updated) >= b.CacheTTL:
+			shared = stored
+			if anchorSize > 0 {
+				posts, err = b.redditBot.UpdateForAnchorSizeContext(ctx, &shared.Feed, anchorSize)
+			} else {
+				posts, err = b.redditBot.UpdateContext(ctx, &shared.Feed)
+			}
+		default:
+			shared = stored
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		shared.add(posts, now)
+
+		return b.db.Update(func(t *bolt.Tx) error {
+			bucket := t.Bucket([]byte(sharedBucketName))
+
+			var current *sharedFeed
+			if data := bucket.Get(key); data != nil {
+				err := json.Unmarshal(data, &current)
+				if err != nil {
+					return err
+				}
+			}
+			if (current == nil) != (stored == nil) || (current != nil && current.Version != stored.Version) {
+				return ConflictError{}
+			}
+
+			shared.Version, err = bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			data, err := json.Marshal(shared)
+			if err != nil {
+				return err
+			}
+			return bucket.Put(key, data)
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return shared, nil
+}
+
+// feedFetcher fetches the posts of a subscription, it may modify the
+// subscription (e.g. its cursor)
+type feedFetcher func(ctx context.Context, sub *subscription) ([]*reddit.Post, error)
+
+// follow moves the cursor of the subscription to the last post of the shared
+// feed, and its anchor to the one of the shared feed
+func (sub *subscription) follow(shared *sharedFeed) {
+	sub.Cursor = shared.Seq
+	sub.Anchor = append(reddit.Anchor(nil), shared.Feed.Anchor...)
+}
+
+// sinceShared returns the posts of the shared feed the subscription didn't
+// receive yet. If some of them were dropped from the cache, the posts are
+// fetched by crawling back to the anchor of the subscription instead, which
+// moves the anchor only if `update` is true. The subscriptions stored before
+// the shared feeds have no cursor: they crawl back to their anchor once.
+func (b *TelegramNotifier) sinceShared(ctx context.Context, sub *subscription, shared *sharedFeed, update bool) ([]*reddit.Post, error) {
+	behind := shared.behind(sub.Cursor) || sub.Cursor == 0
+	if !behind || len(sub.Anchor) == 0 {
+		return shared.since(sub.Cursor), nil
+	}
+	if update {
+		return b.redditBot.UpdateContext(ctx, &sub.Feed)
+	}
+	return b.redditBot.PeekContext(ctx, &sub.Feed)
+}
+
+// updateShared returns the posts of the shared feed the subscription didn't
+// receive yet, and moves its cursor past them
+func (b *TelegramNotifier) updateShared(ctx context.Context, sub *subscription) ([]*reddit.Post, error) {
+	shared, err := b.refreshShared(ctx, sub.Subreddits, 0, false)
+	if err != nil {
+		return nil, err
+	}
+
+	posts, err := b.sinceShared(ctx, sub, shared, true)
+	if err != nil {
+		return nil, err
+	}
+	sub.follow(shared)
+	return posts, nil
+}
+
+// peekShared is like updateShared without moving the cursor
+func (b *TelegramNotifier) peekShared(ctx context.Context, sub *subscription) ([]*reddit.Post, error) {
+	shared, err := b.refreshShared(ctx, sub.Subreddits, 0, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.sinceShared(ctx, sub, shared, false)
+}
+
+// touchShared updates the shared feed, returns its newest posts and moves the
+// cursor of the subscription past them
+func (b *TelegramNotifier) touchShared(ctx context.Context, sub *subscription) ([]*reddit.Post, error) {
+	shared, err := b.refreshShared(ctx, sub.Subreddits, 0, true)
+	if err != nil {
+		return nil, err
+	}
+
+	sub.follow(shared)
+	return shared.latest(touchedPosts), nil
+}
+
+// growShared returns a fetcher like updateShared which also sets the size of
+// the anchor of the shared feed. The anchor of a feed shared with other
+// subscriptions can't be changed (see sharedWithOthers).
+func (b *TelegramNotifier) growShared(anchorSize int) feedFetcher {
+	return func(ctx context.Context, sub *subscription) ([]*reddit.Post, error) {
+		shared, err := b.refreshShared(ctx, sub.Subreddits, anchorSize, true)
+		if err != nil {
+			return nil, err
+		}
+
+		posts, err := b.sinceShared(ctx, sub, shared, true)
+		if err != nil {
+			return nil, err
+		}
+		sub.follow(shared)
+		return posts, nil
+	}
+}
+
+// joinShared sets up a new subscription to follow the shared feed of its
+// subreddits from its last post, the shared feed is created if needed
+func (b *TelegramNotifier) joinShared(ctx context.Context, sub *subscription) error {
+	shared, err := b.refreshShared(ctx, sub.Subreddits, 0, false)
+	if err != nil {
+		return err
+	}
+	sub.follow(shared)
+	return nil
+}
+
+// followers returns the number of subscriptions following the shared feed of
+// `subreddits`
+func followers(t *bolt.Tx, subreddits string) (int, error) {
+	key := canonicalSubreddits(subreddits)
+
+	count := 0
+	err := t.Bucket([]byte(bucketName)).ForEach(func(k, v []byte) error {
+		var sub *subscription
+		err := json.Unmarshal(v, &sub)
+		if err != nil {
+			return err
+		}
+		if canonicalSubreddits(sub.Subreddits) == key {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// sharedWithOthers returns true if the shared feed of the feed `name` of
+// chatID is followed by other subscriptions
+func (b *TelegramNotifier) sharedWithOthers(chatID int64, name string) (bool, error) {
+	sub, err := b.subscription(chatID, name)
+	if err != nil {
+		return false, err
+	}
+
+	var count int
+	err = b.db.View(func(t *bolt.Tx) error {
+		count, err = followers(t, sub.Subreddits)
+		return err
+	})
+	return count > 1, err
+}
+
+// leaveShared deletes the shared feed of `subreddits` if no subscription
+// follows it anymore
+func leaveShared(t *bolt.Tx, subreddits string) error {
+	count, err := followers(t, subreddits)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	return t.Bucket([]byte(sharedBucketName)).Delete([]byte(canonicalSubreddits(subreddits)))
+}
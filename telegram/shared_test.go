@@ -0,0 +1,321 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	"github.com/stretchr/testify/assert"
+	goreddit "github.com/vartanbeno/go-reddit/v2/reddit"
+	bolt "go.etcd.io/bbolt"
+)
+
+// countingFetcher counts the calls to the reddit API
+type countingFetcher struct {
+	*reddit.FakeBot
+	calls int
+}
+
+func (f *countingFetcher) TouchContext(ctx context.Context, feed *reddit.Feed) ([]*reddit.Post, error) {
+	f.calls++
+	return f.FakeBot.TouchContext(ctx, feed)
+}
+
+func (f *countingFetcher) UpdateContext(ctx context.Context, feed *reddit.Feed) ([]*reddit.Post, error) {
+	f.calls++
+	return f.FakeBot.UpdateContext(ctx, feed)
+}
+
+// hookFetcher calls onUpdate while reddit is being called
+type hookFetcher struct {
+	*reddit.FakeBot
+	onUpdate func()
+}
+
+func (f *hookFetcher) UpdateContext(ctx context.Context, feed *reddit.Feed) ([]*reddit.Post, error) {
+	f.onUpdate()
+	return f.FakeBot.UpdateContext(ctx, feed)
+}
+
+// newTestFakeBot returns a FakeBot with ten posts in r/MechanicalKeyboards,
+// from "a" (the newest) to "j"
+func newTestFakeBot() *reddit.FakeBot {
+	return reddit.NewFakeBot(newTestPosts())
+}
+
+// newTestPosts returns the posts of newTestFakeBot
+func newTestPosts() []*reddit.Post {
+	now := time.Now()
+	var posts []*reddit.Post
+	for i := 0; i < 10; i++ {
+		post := &reddit.Post{}
+		post.FullID = string(rune('a' + i))
+		post.Created = &goreddit.Timestamp{Time: now.Add(-time.Duration(i) * time.Minute)}
+		post.SubredditName = "MechanicalKeyboards"
+		posts = append(posts, post)
+	}
+	return posts
+}
+
+func TestCanonicalSubreddits(t *testing.T) {
+	assert.Equal(t, "keyboards+mechmarket", canonicalSubreddits("mechmarket+Keyboards+keyboards"))
+}
+
+func TestSharedFeed(t *testing.T) {
+	fetcher := &countingFetcher{FakeBot: newTestFakeBot()}
+	b := getTestNotifier(t)
+	b.redditBot = fetcher
+
+	// ten chats follow the same subreddit
+	ctx := context.Background()
+	for chatID := int64(0); chatID < 10; chatID++ {
+		joinTestFeed(t, b, chatID)
+	}
+	assert.Equal(t, 1, fetcher.calls)
+
+	// every chat gets the same posts, reddit is called once within CacheTTL
+	for chatID := int64(0); chatID < 10; chatID++ {
+		fetched, err := b.fetchFeed(ctx, chatID, DefaultFeedName, b.updateShared)
+		assert.Nil(t, err)
+		assert.Empty(t, fetched)
+	}
+	assert.Equal(t, 1, fetcher.calls)
+
+	// an outdated cache is updated once
+	b.CacheTTL = 0
+	_, err := b.fetchFeed(ctx, 0, DefaultFeedName, b.updateShared)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, fetcher.calls)
+
+	// touching returns the newest posts and moves the cursor
+	fetched, err := b.fetchFeed(ctx, 1, DefaultFeedName, b.touchShared)
+	assert.Nil(t, err)
+	assert.Len(t, fetched, touchedPosts)
+	assert.Equal(t, "a", fetched[0].FullID)
+
+	// the shared feed is deleted with its last subscriber
+	for chatID := int64(0); chatID < 10; chatID++ {
+		assert.Nil(t, b.removeListener(chatID, DefaultFeedName))
+	}
+	var data []byte
+	err = b.db.View(func(tx *bolt.Tx) error {
+		data = tx.Bucket([]byte(sharedBucketName)).Get([]byte("mechanicalkeyboards"))
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, data)
+}
+
+func TestSharedFeedCursor(t *testing.T) {
+	shared := &sharedFeed{}
+
+	newPost := func(id string) *reddit.Post {
+		post := &reddit.Post{}
+		post.FullID = id
+		return post
+	}
+
+	shared.add([]*reddit.Post{newPost("b"), newPost("a")}, time.Now())
+	assert.Equal(t, uint64(2), shared.Seq)
+	cursor := shared.Seq
+
+	shared.add([]*reddit.Post{newPost("d"), newPost("c")}, time.Now())
+	since := shared.since(cursor)
+	assert.Len(t, since, 2)
+	assert.Equal(t, "d", since[0].FullID)
+	assert.Equal(t, "c", since[1].FullID)
+	assert.Len(t, shared.since(0), 4)
+}
+
+func TestSharedFeedPruned(t *testing.T) {
+	shared := &sharedFeed{}
+	old := &reddit.Post{}
+	old.FullID = "old"
+	old.Created = &goreddit.Timestamp{Time: time.Now().Add(-2 * cacheWindow)}
+	shared.add([]*reddit.Post{old}, time.Now())
+	assert.Empty(t, shared.Posts)
+	assert.True(t, shared.behind(0))
+	assert.False(t, shared.behind(1))
+}
+
+func TestSubscriptionBehindCache(t *testing.T) {
+	posts := newTestPosts()
+	for _, id := range []string{"x", "y", "z"} {
+		post := &reddit.Post{}
+		post.FullID = id
+		post.Created = &goreddit.Timestamp{Time: time.Now().Add(200 * time.Millisecond)}
+		post.SubredditName = "MechanicalKeyboards"
+		posts = append(posts, post)
+	}
+
+	b := getTestNotifier(t)
+	b.redditBot = reddit.NewFakeBot(posts)
+	joinTestFeed(t, b, 0)
+	joinTestFeed(t, b, 1)
+
+	// chat 1 receives the new posts, which are then dropped from the cache
+	time.Sleep(300 * time.Millisecond)
+	b.CacheTTL = 0
+	fetched, err := b.fetchFeed(context.Background(), 1, DefaultFeedName, b.updateShared)
+	assert.Nil(t, err)
+	assert.Len(t, fetched, 3)
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(sharedBucketName))
+		var shared *sharedFeed
+		err := json.Unmarshal(bucket.Get([]byte("mechanicalkeyboards")), &shared)
+		if err != nil {
+			return err
+		}
+		shared.Posts = nil
+		shared.Pruned = shared.Seq
+		data, err := json.Marshal(shared)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte("mechanicalkeyboards"), data)
+	})
+	assert.Nil(t, err)
+
+	// chat 0 crawls back to its own anchor to get them
+	b.CacheTTL = time.Hour
+	fetched, err = b.fetchFeed(context.Background(), 0, DefaultFeedName, b.updateShared)
+	assert.Nil(t, err)
+	assert.Len(t, fetched, 3)
+
+	// and then follows the shared feed again
+	fetched, err = b.fetchFeed(context.Background(), 0, DefaultFeedName, b.updateShared)
+	assert.Nil(t, err)
+	assert.Empty(t, fetched)
+}
+
+func TestMigratedSubscription(t *testing.T) {
+	// a feed stored before the shared feeds, anchored on the 8th of 12 posts
+	var posts []*reddit.Post
+	for i := 0; i < 12; i++ {
+		post := &reddit.Post{}
+		post.FullID = string(rune('a' + i))
+		post.Created = &goreddit.Timestamp{Time: time.Now().Add(-time.Duration(i) * time.Minute)}
+		post.SubredditName = "MechanicalKeyboards"
+		posts = append(posts, post)
+	}
+	feed, err := reddit.NewFakeBot(posts[7:]).NewFeedContext(context.Background(), "MechanicalKeyboards")
+	assert.Nil(t, err)
+	data, err := json.Marshal(feed)
+	assert.Nil(t, err)
+
+	b := getTestNotifier(t)
+	b.redditBot = reddit.NewFakeBot(posts)
+	err = b.db.Update(func(t *bolt.Tx) error {
+		err := t.Bucket([]byte(bucketName)).Put(chatKey(0), data)
+		if err != nil {
+			return err
+		}
+		return migrateFeeds(t)
+	})
+	assert.Nil(t, err)
+
+	// the posts published since the anchor aren't lost with the touch of the
+	// new shared feed
+	fetched, err := b.fetchFeed(context.Background(), 0, DefaultFeedName, b.updateShared)
+	assert.Nil(t, err)
+	assert.Len(t, fetched, 7)
+
+	sub, err := b.subscription(0, DefaultFeedName)
+	assert.Nil(t, err)
+	assert.NotZero(t, sub.Cursor)
+	fetched, err = b.fetchFeed(context.Background(), 0, DefaultFeedName, b.updateShared)
+	assert.Nil(t, err)
+	assert.Empty(t, fetched)
+}
+
+func TestSharedWithOthers(t *testing.T) {
+	b := getTestNotifier(t)
+	b.redditBot = newTestFakeBot()
+	joinTestFeed(t, b, 0)
+
+	shared, err := b.sharedWithOthers(0, DefaultFeedName)
+	assert.Nil(t, err)
+	assert.False(t, shared)
+
+	joinTestFeed(t, b, 1)
+	shared, err = b.sharedWithOthers(0, DefaultFeedName)
+	assert.Nil(t, err)
+	assert.True(t, shared)
+}
+
+// joinTestFeed subscribes chatID to r/MechanicalKeyboards
+func joinTestFeed(t *testing.T, b *TelegramNotifier, chatID int64) {
+	sub := &subscription{Feed: reddit.Feed{Subreddits: "MechanicalKeyboards"}}
+	assert.Nil(t, b.joinShared(context.Background(), sub))
+	assert.Nil(t, b.addListeners(chatID, DefaultFeedName, sub))
+}
+
+func TestFetchFeedOutsideTransaction(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	fetcher := &hookFetcher{FakeBot: newTestFakeBot(), onUpdate: func() {
+		close(entered)
+		<-release
+	}}
+	b := getTestNotifier(t)
+	b.redditBot = fetcher
+	b.CacheTTL = 0
+	joinTestFeed(t, b, 0)
+
+	fetched := make(chan error)
+	go func() {
+		_, err := b.fetchFeed(context.Background(), 0, DefaultFeedName, b.updateShared)
+		fetched <- err
+	}()
+	<-entered
+
+	// another chat can subscribe while reddit is being called
+	subscribed := make(chan error)
+	go func() {
+		subscribed <- b.addListeners(1, DefaultFeedName, &subscription{})
+	}()
+	select {
+	case err := <-subscribed:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Error("the database is locked during the request")
+	}
+
+	close(release)
+	assert.Nil(t, <-fetched)
+}
+
+func TestFetchFeedConflict(t *testing.T) {
+	b := getTestNotifier(t)
+	calls := 0
+	b.redditBot = &hookFetcher{FakeBot: newTestFakeBot(), onUpdate: func() {
+		calls++
+		if calls == 1 {
+			// the subscription is modified during the first request
+			assert.Nil(t, b.setInterval(0, DefaultFeedName, time.Hour))
+		}
+	}}
+	b.CacheTTL = 0
+	joinTestFeed(t, b, 0)
+
+	_, err := b.fetchFeed(context.Background(), 0, DefaultFeedName, b.updateShared)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+
+	// the concurrent modification isn't overwritten
+	sub, err := b.subscription(0, DefaultFeedName)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Hour, sub.Interval)
+}
+
+func TestRetryOnConflict(t *testing.T) {
+	attempts := 0
+	err := retryOnConflict(func() error {
+		attempts++
+		return ConflictError{}
+	})
+	assert.IsType(t, ConflictError{}, err)
+	assert.Equal(t, 1+maxConflictRetries, attempts)
+}
@@ -0,0 +1,246 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	"github.com/maxime915/mk-giveaway-notifier/sink"
+	telegram "gopkg.in/tucnak/telebot.v2"
+)
+
+// chatSink is the sink sending the notifications to a Telegram chat, with
+// the 👍/👎 buttons
+type chatSink struct {
+	b  *TelegramNotifier
+	to telegram.Recipient
+}
+
+func (s chatSink) Send(ctx context.Context, n *sink.Notification) error {
+	_, err := s.b.Send(s.to, n.Text(), labelKeyboard(n.ID))
+	return err
+}
+
+// notification renders the notification of a post
+func (b *TelegramNotifier) notification(post *reddit.Post) *sink.Notification {
+	n := &sink.Notification{
+		ID:             post.FullID,
+		Title:          post.Title,
+		Author:         post.Author,
+		Subreddit:      post.SubredditName,
+		Permalink:      post.Permalink,
+		Eligibility:    eligibility(post),
+		Classification: b.classify(post),
+	}
+	if post.Created != nil {
+		n.Created = post.Created.Time
+	}
+	if end, ok := deadline(post); ok {
+		n.Deadline = end
+	}
+	return n
+}
+
+// feedSinks returns the sinks of the feed `name` of chatID, `to` is the
+// Telegram sink. A feed without sinks sends its notifications to `to`.
+func (b *TelegramNotifier) feedSinks(chatID int64, name string, to telegram.Recipient) ([]sink.Sink, error) {
+	sub, err := b.subscription(chatID, name)
+	switch err.(type) {
+	case nil:
+	case KeyNotFoundError:
+		return []sink.Sink{chatSink{b, to}}, nil
+	default:
+		return nil, err
+	}
+
+	if len(sub.Sinks) == 0 {
+		return []sink.Sink{chatSink{b, to}}, nil
+	}
+
+	sinks := make([]sink.Sink, 0, len(sub.Sinks))
+	for i, config := range sub.Sinks {
+		if config.Type == sink.TypeTelegram {
+			sinks = append(sinks, chatSink{b, to})
+			continue
+		}
+
+		s, err := sink.New(config)
+		if err != nil {
+			return nil, err
+		}
+		if webhook, ok := s.(*sink.Webhook); ok {
+			webhook.DeadLetters = chatDeadLetters{b, chatID}
+		}
+		if email, ok := s.(*sink.Email); ok {
+			email.Unsubscribe = fmt.Sprintf(
+				"To stop these emails, send \"/sink %s remove %d\" to the bot on Telegram.",
+				name, i+1,
+			)
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+// describeSinks lists the sinks of a subscription, numbered for /sink remove.
+// The chat is the default sink, it isn't numbered as it can't be removed.
+func describeSinks(configs []sink.Config) string {
+	if len(configs) == 0 {
+		return "telegram (this chat, default until another sink is added)"
+	}
+
+	lines := make([]string, len(configs))
+	for i, config := range configs {
+		lines[i] = fmt.Sprintf("%d. %s", i+1, config)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// testSink sends a test notification to the sink of `config`, the chat
+// itself isn't tested
+func (b *TelegramNotifier) testSink(config sink.Config) error {
+	if config.Type == sink.TypeTelegram {
+		return nil
+	}
+
+	s, err := sink.New(config)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(b.ctx, time.Minute)
+	defer cancel()
+	err = s.Send(ctx, &sink.Notification{
+		Title:     "Test notification",
+		Author:    "mk-giveaway-notifier",
+		Permalink: "/r/" + subreddit,
+		Created:   time.Now(),
+	})
+	if flusher, ok := s.(sink.Flusher); ok && err == nil {
+		err = flusher.Flush(ctx)
+	}
+	return err
+}
+
+// checkSinks validates the sinks of a subscription given by a user as
+// /sink add does: each one is parsed and sent a test notification. The
+// sinks are replaced by their parsed configuration. The returned message
+// explains the first invalid sink, if any.
+func (b *TelegramNotifier) checkSinks(sub *subscription) string {
+	for i, config := range sub.Sinks {
+		checked, err := sink.Parse(config.Type, string(config.Params))
+		if err != nil {
+			return fmt.Sprintf("Invalid sink %d: %v", i+1, err)
+		}
+		err = b.testSink(checked)
+		if err != nil {
+			return fmt.Sprintf("Unable to send a test notification to %s: %s", checked, sink.PublicError(err))
+		}
+		sub.Sinks[i] = checked
+	}
+	return ""
+}
+
+// replySink configures the sinks of a feed of the chat from the payload of
+// `m`: "[feed] add <type> [parameters]", "[feed] remove <number>" or "[feed]"
+// alone to list them.
+func (b *TelegramNotifier) replySink(m *telegram.Message) error {
+	names, payload, err := b.selectFeeds(m.Chat.ID, m.Payload, false)
+	if err != nil {
+		return b.replyFeedError(m, err)
+	}
+	name := names[0]
+
+	usage := "Usage: /sink [feed] add <type> [parameters], /sink [feed] remove <number>, /sink [feed] to list them.\n" +
+		"Types: " + strings.Join(sink.Types, ", ") + " (e.g. /sink add discord https://discord.com/api/webhooks/...)"
+
+	fields := strings.Fields(payload)
+	if len(fields) == 0 {
+		sub, err := b.subscription(m.Chat.ID, name)
+		if err != nil {
+			return b.replyFeedError(m, err)
+		}
+		_, err = b.Send(m.Sender, fmt.Sprintf("Sinks%s:\n%s", feedLabel(name), describeSinks(sub.Sinks)), "Markdown")
+		return err
+	}
+
+	var update func(*subscription) error
+	switch strings.ToLower(fields[0]) {
+	case "add":
+		if len(fields) < 2 {
+			_, err = b.Send(m.Sender, usage)
+			return err
+		}
+		params := strings.TrimSpace(strings.TrimPrefix(payload, fields[0]))
+		params = strings.TrimSpace(strings.TrimPrefix(params, fields[1]))
+		config, err := sink.Parse(fields[1], params)
+		if err != nil {
+			_, err = b.Send(m.Sender, fmt.Sprintf("Invalid sink: %v\n%s", err, usage))
+			return err
+		}
+
+		// the sinks are tested before being added
+		err = b.testSink(config)
+		if err != nil {
+			_, err = b.Send(m.Sender, fmt.Sprintf("Unable to send a test notification to %s: %s", config, sink.PublicError(err)))
+			return err
+		}
+
+		update = func(sub *subscription) error {
+			if len(sub.Sinks) == 0 {
+				// the chat keeps its notifications until it removes them
+				sub.Sinks = []sink.Config{{Type: sink.TypeTelegram}}
+			}
+			for _, existing := range sub.Sinks {
+				if existing.Type == config.Type && string(existing.Params) == string(config.Params) {
+					return KeyExistError{}
+				}
+			}
+			sub.Sinks = append(sub.Sinks, config)
+			return nil
+		}
+	case "remove":
+		index := 0
+		if len(fields) == 2 {
+			index, _ = strconv.Atoi(fields[1])
+		}
+		update = func(sub *subscription) error {
+			if index < 1 || index > len(sub.Sinks) {
+				return KeyNotFoundError{}
+			}
+			sub.Sinks = append(sub.Sinks[:index-1], sub.Sinks[index:]...)
+			return nil
+		}
+	default:
+		_, err = b.Send(m.Sender, usage)
+		return err
+	}
+
+	var sinks []sink.Config
+	err = b.updateSubscription(m.Chat.ID, name, func(sub *subscription) error {
+		err := update(sub)
+		sinks = sub.Sinks
+		return err
+	})
+	switch err.(type) {
+	case nil:
+	case KeyExistError:
+		_, err = b.Send(m.Sender, "This sink is already configured.")
+		return err
+	case KeyNotFoundError:
+		message := "No such sink, see the numbers given by /sink."
+		if len(sinks) == 0 {
+			message = "This chat is the default sink, it is replaced by the first sink added with /sink add."
+		}
+		_, err = b.Send(m.Sender, message)
+		return err
+	default:
+		b.Send(m.Sender, "Unable to save the sinks, see logs for detail.")
+		return err
+	}
+
+	_, err = b.Send(m.Sender, fmt.Sprintf("Sinks%s:\n%s", feedLabel(name), describeSinks(sinks)), "Markdown")
+	return err
+}
@@ -0,0 +1,176 @@
+package telegram
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	"github.com/maxime915/mk-giveaway-notifier/sink"
+	"github.com/stretchr/testify/assert"
+	goreddit "github.com/vartanbeno/go-reddit/v2/reddit"
+)
+
+func TestMain(m *testing.M) {
+	// the local stand-ins of the services listen on the loopback
+	sink.AllowPrivateNetworks = true
+	os.Exit(m.Run())
+}
+
+func TestDeliverToSinks(t *testing.T) {
+	var titles []string
+	discord := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message struct {
+			Embeds []struct {
+				Title string `json:"title"`
+			} `json:"embeds"`
+		}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&message))
+		titles = append(titles, message.Embeds[0].Title)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer discord.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	b := getTestNotifier(t)
+	config := func(url string) sink.Config {
+		config, err := sink.Parse(sink.TypeDiscord, url)
+		assert.Nil(t, err)
+		return config
+	}
+	assert.Nil(t, b.addListeners(0, DefaultFeedName, &subscription{
+		Sinks: []sink.Config{config(failing.URL), config(discord.URL)},
+	}))
+
+	post := &reddit.Post{}
+	post.FullID = "t3_a"
+	post.Title = "Keycaps [Giveaway]"
+	post.Created = &goreddit.Timestamp{Time: time.Now()}
+	all := func(*reddit.Post) bool { return true }
+
+	// the post reaches the working sink despite the failing one
+	count, err := b.deliverPosts(0, DefaultFeedName, nil, []*reddit.Post{post}, all)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []string{"Keycaps [Giveaway]"}, titles)
+
+	// and it is remembered as sent
+	count, err = b.deliverPosts(0, DefaultFeedName, nil, []*reddit.Post{post}, all)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+	assert.Len(t, titles, 1)
+}
+
+func TestDigestFlushFailure(t *testing.T) {
+	// nothing listens on the port of a closed server
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(closed.URL, "http://"))
+	assert.Nil(t, err)
+
+	b := getTestNotifier(t)
+	config, err := sink.Parse(sink.TypeEmail, `{"host": "`+host+`", "port": `+port+`, "from": "bot@example.com", "to": ["me@example.com"], "digest": true}`)
+	assert.Nil(t, err)
+	assert.Nil(t, b.addListeners(0, DefaultFeedName, &subscription{Sinks: []sink.Config{config}}))
+
+	post := &reddit.Post{}
+	post.FullID = "t3_a"
+	post.Created = &goreddit.Timestamp{Time: time.Now()}
+	all := func(*reddit.Post) bool { return true }
+
+	// the queued post isn't delivered when the digest fails
+	count, err := b.deliverPosts(0, DefaultFeedName, nil, []*reddit.Post{post}, all)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, count)
+
+	seen, err := b.seenPosts(0)
+	assert.Nil(t, err)
+	assert.Empty(t, seen)
+}
+
+func TestDescribeSinks(t *testing.T) {
+	config, err := sink.Parse(sink.TypeDiscord, "https://discord.com/api/webhooks/1/secret")
+	assert.Nil(t, err)
+
+	assert.Equal(t, "telegram (this chat, default until another sink is added)", describeSinks(nil))
+	assert.Equal(t, "1. telegram\n2. discord (discord.com)", describeSinks([]sink.Config{{Type: sink.TypeTelegram}, config}))
+}
+
+func TestDeadLetters(t *testing.T) {
+	gone := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer gone.Close()
+
+	b := getTestNotifier(t)
+	config, err := sink.Parse(sink.TypeWebhook, `{"url": "`+gone.URL+`", "secret": "secret"}`)
+	assert.Nil(t, err)
+	assert.Nil(t, b.addListeners(0, DefaultFeedName, &subscription{Sinks: []sink.Config{config}}))
+
+	post := &reddit.Post{}
+	post.FullID = "t3_a"
+	post.Created = &goreddit.Timestamp{Time: time.Now()}
+	all := func(*reddit.Post) bool { return true }
+
+	count, err := b.deliverPosts(0, DefaultFeedName, nil, []*reddit.Post{post}, all)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, count)
+
+	letters, err := b.deadLetters(0)
+	assert.Nil(t, err)
+	assert.Len(t, letters, 1)
+	assert.Equal(t, gone.URL, letters[0].URL)
+	assert.Contains(t, string(letters[0].Payload), `"id":"t3_a"`)
+
+	// only the newest letters are kept
+	for i := 0; i < maxDeadLetters; i++ {
+		assert.Nil(t, chatDeadLetters{b, 0}.Record(sink.DeadLetter{Attempts: i}))
+	}
+	letters, err = b.deadLetters(0)
+	assert.Nil(t, err)
+	assert.Len(t, letters, maxDeadLetters)
+	assert.Equal(t, maxDeadLetters-1, letters[0].Attempts)
+	assert.Equal(t, 0, letters[maxDeadLetters-1].Attempts)
+}
+
+func TestDebugHidesSecrets(t *testing.T) {
+	b := getTestNotifier(t)
+	assert.Nil(t, b.addListeners(0, DefaultFeedName, &subscription{
+		Sinks: []sink.Config{
+			{Type: sink.TypeWebhook, Params: json.RawMessage(`{"url": "https://example.com/hook", "secret": "hmac-secret"}`)},
+			{Type: sink.TypeEmail, Params: json.RawMessage(`{"host": "smtp.example.com", "password": "smtp-password"}`)},
+			{Type: sink.TypeMatrix, Params: json.RawMessage(`{"homeserver": "https://matrix.org", "access_token": "matrix-token"}`)},
+			{Type: sink.TypeNtfy, Params: json.RawMessage(`{"url": "https://ntfy.sh/mk", "token": "ntfy-token"}`)},
+			{Type: sink.TypeDiscord, Params: json.RawMessage(`{"url": "https://discord.com/api/webhooks/1/discord-token"}`)},
+		},
+	}))
+
+	debug := b.String()
+	for _, secret := range []string{"hmac-secret", "smtp-password", "matrix-token", "ntfy-token", "discord-token"} {
+		assert.NotContains(t, debug, secret)
+	}
+	assert.Contains(t, debug, "webhook (example.com)")
+	assert.Contains(t, debug, "email (smtp.example.com)")
+}
+
+func TestRemoveDefaultSink(t *testing.T) {
+	api, _, stop := launchWithFakeBotAPI(t)
+	defer stop()
+
+	api.send("/subscribe")
+	api.receive(t)
+
+	api.send("/sink")
+	assert.Equal(t, "Sinks:\ntelegram (this chat, default until another sink is added)", api.receive(t))
+	api.send("/sink remove 1")
+	assert.Equal(t, "This chat is the default sink, it is replaced by the first sink added with /sink add.", api.receive(t))
+}
@@ -0,0 +1,106 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	"github.com/maxime915/mk-giveaway-notifier/sink"
+	bolt "go.etcd.io/bbolt"
+)
+
+// subscription is the value stored for each chat : the reddit.Feed it listens
+// to and the settings of that feed.
+// The fields of the Feed are embedded so that a Feed stored before the
+// settings existed is still a valid subscription.
+type subscription struct {
+	reddit.Feed
+	// Interval between two automatic updates, the scheduler's default is
+	// used if zero
+	Interval time.Duration `json:"interval,omitempty"`
+	// Filter is the filter expression selecting the posts sent to the chat
+	// (see package filter), only the giveaways are sent if empty
+	Filter string `json:"filter,omitempty"`
+	// Cursor is the number of the last post of the shared feed of the
+	// subreddits received by the subscription (see sharedFeed). The anchor of
+	// the embedded Feed is a copy of the anchor of the shared feed at the
+	// cursor, to crawl back to when the cache doesn't reach the cursor.
+	Cursor uint64 `json:"cursor,omitempty"`
+	// Sinks receive the notifications of the subscription, the chat if empty
+	Sinks []sink.Config `json:"sinks,omitempty"`
+	// Version changes each time the subscription is stored (see
+	// retryOnConflict)
+	Version uint64 `json:"version,omitempty"`
+}
+
+// updateSubscription applies `update` to the subscription `name` of chatID
+// and stores it
+func (b *TelegramNotifier) updateSubscription(chatID int64, name string, update func(*subscription) error) error {
+	return b.db.Update(func(t *bolt.Tx) error {
+		key := feedKey(chatID, name)
+
+		bucket := t.Bucket([]byte(bucketName))
+
+		data := bucket.Get(key)
+		if data == nil {
+			return KeyNotFoundError{}
+		}
+
+		var sub *subscription
+		err := json.Unmarshal(data, &sub)
+		if err != nil {
+			return err
+		}
+
+		err = update(sub)
+		if err != nil {
+			return err
+		}
+
+		sub.Version, err = bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		data, err = json.Marshal(sub)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(key, data)
+	})
+}
+
+// checkSubreddits validates the subreddits listed in `payload` (the default
+// subreddit if empty) and returns their names as spelled by reddit. If some
+// subreddits can't be followed, the messages explaining why are returned
+// instead.
+func (b *TelegramNotifier) checkSubreddits(ctx context.Context, payload string) ([]string, []string, error) {
+	names := strings.Fields(strings.ReplaceAll(payload, ",", " "))
+	if len(names) == 0 {
+		names = []string{subreddit}
+	}
+
+	var subreddits, problems []string
+	found := make(map[string]bool)
+	for _, name := range names {
+		name, err := b.redditBot.CheckSubredditContext(ctx, name)
+		switch err := err.(type) {
+		case nil:
+		case *reddit.SubredditError:
+			problems = append(problems, describeSubredditError(err))
+			continue
+		default:
+			return nil, nil, err
+		}
+
+		if !found[strings.ToLower(name)] {
+			found[strings.ToLower(name)] = true
+			subreddits = append(subreddits, name)
+		}
+	}
+
+	return subreddits, problems, nil
+}
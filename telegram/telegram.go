@@ -3,18 +3,25 @@
 // NewTelegramNotifier or you can load one from a save file.
 // The method Stop allow for a graceful shutdown although it
 // not wait for the bot to shut down before returning : some
-// processing may still be ongoing.
+// processing may still be ongoing. The calls to the reddit API
+// in progress are cancelled by Stop.
 package telegram
 
 import (
-	"encoding/binary"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/maxime915/mk-giveaway-notifier/giveaway"
 	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	"github.com/maxime915/mk-giveaway-notifier/sink"
 	bolt "go.etcd.io/bbolt"
 	telegram "gopkg.in/tucnak/telebot.v2"
 )
@@ -25,20 +32,63 @@ const (
 	bucketName = "main-bucket" // one global bucket
 )
 
+// operationTimeout is the maximum duration of the reddit calls made to answer
+// one message or to perform one scheduled update
+const operationTimeout = 5 * time.Minute
+
 // TelegramNotifier
 type TelegramNotifier struct {
 	*telegram.Bot
-	redditBot *reddit.Bot
+	redditBot reddit.Fetcher
 	db        *bolt.DB
 	done      chan struct{}
-	started   bool
+	stopOnce  sync.Once
+	// started is true once the bot listens to Telegram, it is guarded by
+	// startMutex as Stop may be called while Launch starts the bot
+	started    bool
+	startMutex sync.Mutex
+	scheduler  sync.WaitGroup
+	// ctx is cancelled by Stop to interrupt the calls to the reddit API
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// PollInterval is the default interval between two automatic updates
+	// of a feed. It must be set before calling Launch.
+	PollInterval time.Duration
+	// PollJitter is the width of the random window centered on the interval
+	// in which the automatic updates happen. It must be set before calling
+	// Launch.
+	PollJitter time.Duration
+	// SeenTTL is the duration for which the posts sent to a chat are
+	// remembered to avoid sending them twice.
+	SeenTTL time.Duration
+	// CacheTTL is the duration for which the posts fetched for a set of
+	// subreddits are served to the subscriptions without calling reddit
+	CacheTTL time.Duration
+	// Classifier tells the giveaways apart from the other posts, it must be
+	// set before calling Launch. It is mixed with a model trained from the
+	// labels given by the users.
+	Classifier *giveaway.Classifier
+
+	classifier *giveaway.Classifier
+	// labelsChanged is true if labels were given since the model was trained
+	labelsChanged   bool
+	classifierMutex sync.RWMutex
 }
 
 // newEmptyBot returns a new empty bot (properties to be filled up)
 func newEmptyBot() *TelegramNotifier {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &TelegramNotifier{
-		done:    make(chan struct{}), // dead channel
-		started: false,
+		done:         make(chan struct{}), // dead channel
+		started:      false,
+		ctx:          ctx,
+		cancel:       cancel,
+		PollInterval: DefaultPollInterval,
+		PollJitter:   DefaultPollJitter,
+		SeenTTL:      DefaultSeenTTL,
+		CacheTTL:     DefaultCacheTTL,
+		Classifier:   giveaway.DefaultClassifier(),
 	}
 }
 
@@ -48,11 +98,60 @@ func NewTelegramNotifier(Token, DBPath string) (*TelegramNotifier, error) {
 }
 
 // NewTelegramNotifierWithBot returns a valid TelegramNotifier with the given token
-// and using the given bot to call the reddit API.
-func NewTelegramNotifierWithBot(Token, DBPath string, redditBot *reddit.Bot) (*TelegramNotifier, error) {
+// and using the given bot to call the reddit API (e.g. a *reddit.Bot, or a
+// *reddit.FakeBot to run offline).
+func NewTelegramNotifierWithBot(Token, DBPath string, redditBot reddit.Fetcher) (*TelegramNotifier, error) {
+	return NewTelegramNotifierWithOptions(Token, DBPath, redditBot, Options{})
+}
+
+// DefaultAPIURL is the base URL of the Bot API of Telegram
+const DefaultAPIURL = telegram.DefaultApiURL
+
+// LongPollTimeout is the duration for which a request of the long poller
+// waits for updates, the timeout of the HTTP client must be longer
+const LongPollTimeout = 30 * time.Second
+
+// Options are the optional settings of the connection to Telegram
+type Options struct {
+	// Poller provides the updates of the bot, e.g. a *Webhook. A long poller
+	// with a LongPollTimeout timeout is used if nil.
+	Poller telegram.Poller
+	// URL is the base URL of the Bot API, e.g. the one of a self-hosted
+	// server (see https://github.com/tdlib/telegram-bot-api). The URL of
+	// Telegram is used if empty.
+	URL string
+	// Client sends the requests to the Bot API, http.DefaultClient is used
+	// if nil
+	Client *http.Client
+}
+
+// NewTelegramNotifierWithOptions returns a valid TelegramNotifier as
+// NewTelegramNotifierWithBot does, connected to Telegram as set by `options`
+func NewTelegramNotifierWithOptions(Token, DBPath string, redditBot reddit.Fetcher, options Options) (*TelegramNotifier, error) {
+	poller := options.Poller
+	if poller == nil {
+		poller = &telegram.LongPoller{Timeout: LongPollTimeout}
+	}
+	if len(options.URL) > 0 {
+		u, err := url.Parse(options.URL)
+		if err != nil {
+			return nil, err
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return nil, fmt.Errorf("%q is not an HTTP(S) URL", options.URL)
+		}
+	}
+	if webhook, ok := poller.(*Webhook); ok {
+		if err := webhook.check(); err != nil {
+			return nil, err
+		}
+	}
+
 	bot, err := telegram.NewBot(telegram.Settings{
+		URL:    strings.TrimRight(options.URL, "/"),
 		Token:  Token,
-		Poller: &telegram.LongPoller{Timeout: 30 * time.Second},
+		Poller: poller,
+		Client: options.Client,
 	})
 
 	if err != nil {
@@ -71,24 +170,43 @@ func NewTelegramNotifierWithBot(Token, DBPath string, redditBot *reddit.Bot) (*T
 	return tgBot, nil
 }
 
-// String represent the current state of the TelegramNotifier
+// debugSubscription is the view of a subscription given by String, its sinks
+// are described without their secrets (see sink.Config.String)
+type debugSubscription struct {
+	*subscription
+	Sinks []string `json:"sinks,omitempty"`
+}
+
+func newDebugSubscription(sub *subscription) debugSubscription {
+	view := debugSubscription{subscription: sub}
+	for _, config := range sub.Sinks {
+		view.Sinks = append(view.Sinks, config.String())
+	}
+	return view
+}
+
+// String represent the current state of the TelegramNotifier, the secrets of
+// the sinks are left out
 func (b *TelegramNotifier) String() string {
-	data := make(map[int64]*reddit.Feed)
+	data := make(map[int64]map[string]debugSubscription)
 
 	err := b.db.View(func(t *bolt.Tx) error {
 		bucket := t.Bucket([]byte(bucketName))
 
 		cursor := bucket.Cursor()
 		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
-			key := int64(binary.BigEndian.Uint64(k))
+			chatID, name := parseFeedKey(k)
 
-			var feed *reddit.Feed
-			err := json.Unmarshal(v, &feed)
+			var sub *subscription
+			err := json.Unmarshal(v, &sub)
 			if err != nil {
 				return err
 			}
 
-			data[key] = feed
+			if data[chatID] == nil {
+				data[chatID] = make(map[string]debugSubscription)
+			}
+			data[chatID][name] = newDebugSubscription(sub)
 		}
 		return nil
 	})
@@ -107,21 +225,27 @@ func (b *TelegramNotifier) String() string {
 	return string(payload)
 }
 
-// add one chat to the listeners, returns false if the chat is already listening
-func (b *TelegramNotifier) addListeners(chatID int64, feed *reddit.Feed) error {
+// add the feed `name` to the listeners of a chat, returns KeyExistError if the
+// chat already has a feed with that name
+func (b *TelegramNotifier) addListeners(chatID int64, name string, sub *subscription) error {
 	return b.db.Update(func(t *bolt.Tx) error {
-		key := make([]byte, 8)
-		binary.BigEndian.PutUint64(key, uint64(chatID))
+		key := feedKey(chatID, name)
 
 		bucket := t.Bucket([]byte(bucketName))
 
-		data, err := json.Marshal(feed)
+		if check := bucket.Get(key); check != nil {
+			return KeyExistError{}
+		}
+
+		var err error
+		sub.Version, err = bucket.NextSequence()
 		if err != nil {
 			return err
 		}
 
-		if check := bucket.Get(key); check != nil {
-			return KeyExistError{}
+		data, err := json.Marshal(sub)
+		if err != nil {
+			return err
 		}
 
 		err = bucket.Put(key, data)
@@ -130,29 +254,73 @@ func (b *TelegramNotifier) addListeners(chatID int64, feed *reddit.Feed) error {
 	})
 }
 
-// remove on chat from the listeners, returns false if chat was not listening
-func (b *TelegramNotifier) removeListener(chatID int64) error {
+// remove the feed `name` of a chat from the listeners, returns
+// KeyNotFoundError if the chat has no such feed. The posts sent to the chat
+// are forgotten with its last feed.
+func (b *TelegramNotifier) removeListener(chatID int64, name string) error {
 	return b.db.Update(func(t *bolt.Tx) error {
-		key := make([]byte, 8)
-		binary.BigEndian.PutUint64(key, uint64(chatID))
+		key := feedKey(chatID, name)
 
 		bucket := t.Bucket([]byte(bucketName))
 
-		if check := bucket.Get(key); check == nil {
+		data := bucket.Get(key)
+		if data == nil {
 			return KeyNotFoundError{}
 		}
 
-		return bucket.Delete(key)
+		var sub *subscription
+		err := json.Unmarshal(data, &sub)
+		if err != nil {
+			return err
+		}
+
+		err = bucket.Delete(key)
+		if err != nil {
+			return err
+		}
+
+		err = leaveShared(t, sub.Subreddits)
+		if err != nil {
+			return err
+		}
+
+		if len(feedNames(t, chatID)) > 0 {
+			return nil
+		}
+
+		err = deleteSeen(t, chatID)
+		if err != nil {
+			return err
+		}
+
+		err = deleteDeadLetters(t, chatID)
+		if err != nil {
+			return err
+		}
+
+		return deleteOpen(t, chatID)
 	})
 }
 
 // Stop makes the bot stop listening to Telegram API. Ongoing requests will continue
-// processing.
+// processing but their calls to the reddit API are cancelled. Calling Stop more
+// than once has no effect.
 func (b *TelegramNotifier) Stop() {
-	if b.started {
-		b.Bot.Stop()
-	}
-	close(b.done)
+	b.stopOnce.Do(func() {
+		b.cancel()
+		b.startMutex.Lock()
+		defer b.startMutex.Unlock()
+		if b.started {
+			b.Bot.Stop()
+		}
+		close(b.done)
+	})
+}
+
+// operationContext returns a context for the reddit calls of one operation,
+// it is cancelled by Stop or after operationTimeout.
+func (b *TelegramNotifier) operationContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(b.ctx, operationTimeout)
 }
 
 // IsKilled returns true if the TelegramNotifier won't start any new processing
@@ -167,64 +335,181 @@ func (b *TelegramNotifier) BlockUntilKilled() {
 	<-b.done
 }
 
-// replyFetchedPosts
-func (b *TelegramNotifier) replyFetchedPosts(m *telegram.Message, fetcher func(*reddit.Feed) ([]*reddit.Post, error)) error {
-	return b.replyFilteredFetchedPosts(m, isGiveaway, fetcher)
-}
+// replyFetchedPosts replies, for each feed of `names`, with the posts fetched
+// by `fetcher` which pass the filter of the feed and that the chat can enter.
+// If `dedupe` is true, the posts already sent to the chat are skipped.
+func (b *TelegramNotifier) replyFetchedPosts(m *telegram.Message, names []string, fetcher feedFetcher, dedupe bool) error {
+	for _, name := range names {
+		filter, err := b.chatFilter(m.Chat.ID, name)
+		if err != nil {
+			b.Send(m.Sender, "Unable to read the settings, see logs for detail.")
+			return err
+		}
 
-// replyFilteredFetchedPosts creates a reply to the Sender of `m` using posts from
-// `fetched` and filtering them via `filter`. Posts included are the one for which's
-// filter(post) is true.
-// The reply is split into a message per post and a confirmation reply. Each post
-// is formatted to show the title, the author and give a permalink.
-func (b *TelegramNotifier) replyFilteredFetchedPosts(m *telegram.Message, filter func(string) bool, fetcher func(*reddit.Feed) ([]*reddit.Post, error)) error {
-	err := b.Notify(m.Sender, telegram.Typing)
-	if err != nil {
-		return err
+		err = b.replyFilteredFetchedPosts(m, name, filter, fetcher, dedupe)
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
+// fetchFeed loads the subscription `name` of chatID, calls `fetcher` on it
+// and stores it back as the fetcher may have modified it (e.g. its cursor).
+func (b *TelegramNotifier) fetchFeed(ctx context.Context, chatID int64, name string, fetcher feedFetcher) ([]*reddit.Post, error) {
 	var posts []*reddit.Post = nil
 
-	err = b.db.Update(func(t *bolt.Tx) error {
-		key := make([]byte, 8)
-		binary.BigEndian.PutUint64(key, uint64(m.Chat.ID))
+	// reddit is called outside of any transaction, see retryOnConflict
+	err := retryOnConflict(func() error {
+		sub, err := b.subscription(chatID, name)
+		if err != nil {
+			return err
+		}
+		version := sub.Version
+
+		posts, err = fetcher(ctx, sub)
+		if err != nil {
+			return err
+		}
 
-		bucket := t.Bucket([]byte(bucketName))
+		return b.db.Update(func(t *bolt.Tx) error {
+			key := feedKey(chatID, name)
 
-		data := bucket.Get(key)
+			bucket := t.Bucket([]byte(bucketName))
 
-		if key == nil {
-			return KeyNotFoundError{}
+			data := bucket.Get(key)
+			if data == nil {
+				return KeyNotFoundError{}
+			}
+
+			var current *subscription
+			err := json.Unmarshal(data, &current)
+			if err != nil {
+				return err
+			}
+			if current.Version != version {
+				return ConflictError{}
+			}
+
+			// fetcher may have modified the subscription, the new value
+			// should be stored
+			sub.Version, err = bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			data, err = json.Marshal(sub)
+			if err != nil {
+				return err
+			}
+
+			return bucket.Put(key, data)
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// sendPosts sends a notification to each sink for each post for which
+// filter(post) is true and returns the posts delivered to at least one sink.
+// A failing sink doesn't prevent the delivery to the others, the first error
+// is returned. The posts queued by a sink.Flusher are delivered only if its
+// Flush succeeds.
+func (b *TelegramNotifier) sendPosts(sinks []sink.Sink, posts []*reddit.Post, filter func(*reddit.Post) bool) ([]*reddit.Post, error) {
+	var sendErr error
+	// delivered[i] are the posts sinks[i] accepted
+	delivered := make([]map[*reddit.Post]bool, len(sinks))
+	for i := range sinks {
+		delivered[i] = make(map[*reddit.Post]bool)
+	}
+
+	var selected []*reddit.Post
+	for _, post := range posts {
+		if filter(post) {
+			selected = append(selected, post)
 		}
+	}
 
-		var feed *reddit.Feed
-		err = json.Unmarshal(data, &feed)
+	// the notifications can be labelled by the users
+	err := b.recordCandidates(selected)
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			return err
+	for _, post := range selected {
+		n := b.notification(post)
+		for i, s := range sinks {
+			ctx, cancel := b.operationContext()
+			err = s.Send(ctx, n)
+			cancel()
+			if err != nil {
+				if sendErr == nil {
+					sendErr = err
+				}
+				continue
+			}
+			delivered[i][post] = true
 		}
+	}
 
-		posts, err = fetcher(feed)
-		if err != nil {
-			return err
+	for i, s := range sinks {
+		if flusher, ok := s.(sink.Flusher); ok {
+			ctx, cancel := b.operationContext()
+			err := flusher.Flush(ctx)
+			cancel()
+			if err != nil {
+				delivered[i] = nil
+				if sendErr == nil {
+					sendErr = err
+				}
+			}
 		}
+	}
 
-		// fetcher may have modified feed, the new value should be stored
-		data, err = json.Marshal(feed)
-		if err != nil {
-			return err
+	var sent []*reddit.Post
+	for _, post := range posts {
+		for i := range sinks {
+			if delivered[i][post] {
+				sent = append(sent, post)
+				break
+			}
 		}
+	}
+	return sent, sendErr
+}
 
-		return bucket.Put(key, data)
-	})
+// replyFilteredFetchedPosts creates a reply to the Sender of `m` using posts from
+// the feed `name` fetched by `fetched` and filtering them via `filter`. Posts included are the one for which's
+// filter(post) is true.
+// The reply is split into a message per post and a confirmation reply. Each post
+// is formatted to show the title, the author and give a permalink.
+// If `dedupe` is true, the posts already sent to the chat are skipped and the
+// posts sent are recorded.
+func (b *TelegramNotifier) replyFilteredFetchedPosts(m *telegram.Message, name string, filter func(*reddit.Post) bool, fetcher feedFetcher, dedupe bool) error {
+	err := b.Notify(m.Sender, telegram.Typing)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := b.operationContext()
+	defer cancel()
+
+	posts, err := b.fetchFeed(ctx, m.Chat.ID, name, fetcher)
 
 	switch err.(type) {
 	case KeyNotFoundError:
 		b.Send(m.Sender, "You are not subscribed to any feed.")
 		return nil
 	case reddit.EmptyAnchorError:
-		b.Send(m.Sender, "The feed has no anchor, /touch it before fetching it")
+		b.Send(m.Sender, fmt.Sprintf("The feed %s has no anchor, /touch it before fetching it", name))
 		return nil
+	case *reddit.RequestError:
+		b.Send(m.Sender, describeRequestError(err.(*reddit.RequestError)))
+		return err
 	default:
 		b.Send(m.Sender, fmt.Sprintf("error while updating feed: %s", err.Error()))
 		return err
@@ -232,26 +517,21 @@ func (b *TelegramNotifier) replyFilteredFetchedPosts(m *telegram.Message, filter
 	}
 
 	if len(posts) == 0 {
-		b.Send(m.Sender, "No post found yet, try again later")
+		b.Send(m.Sender, fmt.Sprintf("No post found yet on %s, try again later", name))
 		return nil
 	}
 
-	count := 0
-	for _, post := range posts {
-		if !filter(post.Title) {
-			continue
-		}
-		count++
-		_, err = b.Send(m.Sender, fmt.Sprintf(
-			"%s by u/%s\nold.reddit.com%s",
-			post.Title,
-			post.Author,
-			post.Permalink,
-		))
-		if err != nil {
-			b.Send(m.Sender, "Error encountered while trying to send results")
-			return err
-		}
+	var count int
+	var sent []*reddit.Post
+	if dedupe {
+		count, err = b.deliverPosts(m.Chat.ID, name, m.Sender, posts, filter)
+	} else {
+		sent, err = b.sendPosts([]sink.Sink{chatSink{b, m.Sender}}, posts, filter)
+		count = len(sent)
+	}
+	if err != nil {
+		b.Send(m.Sender, "Error encountered while trying to send results")
+		return err
 	}
 
 	if len(posts) == 1 {
@@ -260,7 +540,8 @@ func (b *TelegramNotifier) replyFilteredFetchedPosts(m *telegram.Message, filter
 			comment = "It was a giveaway."
 		}
 		_, err = b.Send(m.Sender, fmt.Sprintf(
-			"Fetched 1 post at *%s*.\n%s",
+			"Fetched 1 post%s at *%s*.\n%s",
+			feedLabel(name),
 			posts[0].Created.Time.Local().Format(time.Stamp),
 			comment,
 		), "Markdown")
@@ -272,15 +553,33 @@ func (b *TelegramNotifier) replyFilteredFetchedPosts(m *telegram.Message, filter
 			comment = fmt.Sprintf("%d of them were giveaways.", count)
 		}
 		_, err = b.Send(m.Sender, fmt.Sprintf(
-			"Fetched %d posts from *%s* to *%s*.\n%s",
+			"Fetched %d posts%s from *%s* to *%s*.\n%s",
 			len(posts),
+			feedLabel(name),
 			posts[len(posts)-1].Created.Time.Local().Format(time.Stamp),
 			posts[0].Created.Time.Local().Format(time.Stamp),
 			comment,
 		), "Markdown")
 	}
+	if err != nil || dedupe {
+		return err
+	}
 
-	return err
+	return b.offerLabels(m.Sender, posts, sent)
+}
+
+// initDB creates the global buckets and migrates the data of the former
+// versions of the bot
+func (b *TelegramNotifier) initDB() error {
+	return b.db.Update(func(t *bolt.Tx) error {
+		for _, name := range []string{bucketName, seenBucketName, settingsBucketName, openBucketName, labelsBucketName, sharedBucketName, deadLetterBucketName} {
+			_, err := t.CreateBucketIfNotExists([]byte(name))
+			if err != nil {
+				return err
+			}
+		}
+		return migrateFeeds(t)
+	})
 }
 
 // Launch starts the bot and blocks until Stop() is called or the bot
@@ -288,11 +587,11 @@ func (b *TelegramNotifier) replyFilteredFetchedPosts(m *telegram.Message, filter
 func (b *TelegramNotifier) Launch() error {
 	errChan := make(chan error)
 
-	// create a global bucket
-	if err := b.db.Update(func(t *bolt.Tx) error {
-		_, err := t.CreateBucketIfNotExists([]byte(bucketName))
+	if err := b.initDB(); err != nil {
 		return err
-	}); err != nil {
+	}
+
+	if err := b.train(); err != nil {
 		return err
 	}
 
@@ -317,19 +616,51 @@ func (b *TelegramNotifier) Launch() error {
 			return
 		}
 
-		feed, err := b.redditBot.NewFeed(subreddit)
+		ctx, cancel := b.operationContext()
+		defer cancel()
+
+		name, payload, err := parseSubscribe(m.Payload)
+		if err != nil {
+			_, err = b.Send(m.Sender, err.Error()+"\nUsage: /subscribe [name] [subreddit...], e.g. /subscribe trades mechmarket keyboards")
+			if err != nil {
+				errChan <- err
+			}
+			return
+		}
+
+		subreddits, problems, err := b.checkSubreddits(ctx, payload)
+		if reqErr, ok := err.(*reddit.RequestError); ok {
+			b.Send(m.Sender, describeRequestError(reqErr))
+			errChan <- err
+			return
+		}
+		if err != nil {
+			b.Send(m.Sender, "Internal error, please re-try later (is your internet connection ok?)")
+			errChan <- err
+			return
+		}
+		if len(problems) > 0 {
+			_, err = b.Send(m.Sender, strings.Join(problems, "\n")+"\nUsage: /subscribe [name] [subreddit...], e.g. /subscribe trades mechmarket keyboards")
+			if err != nil {
+				errChan <- err
+			}
+			return
+		}
+
+		sub := &subscription{Feed: reddit.Feed{Subreddits: strings.Join(subreddits, "+")}}
+		err = b.joinShared(ctx, sub)
 		if err != nil {
 			b.Send(m.Sender, "Internal error, please re-try later (is your internet connection ok?)")
 			errChan <- err
 			return
 		}
 
-		err = b.addListeners(m.Chat.ID, feed)
+		err = b.addListeners(m.Chat.ID, name, sub)
 		switch err.(type) {
 		case nil:
-			_, err = b.Send(m.Sender, "Noted, you are now listening on mk-giveaway-notifier.")
+			_, err = b.Send(m.Sender, fmt.Sprintf("Noted, your feed %s now listens on r/%s.", name, strings.Join(subreddits, "+")))
 		case KeyExistError:
-			_, err = b.Send(m.Sender, "You already listen to mk-giveaway-notifier.")
+			_, err = b.Send(m.Sender, fmt.Sprintf("You already have a feed named %s, /unsubscribe %s first or choose another name.", name, name))
 		default:
 			b.Send(m.Sender, "Unable to subscribe, see logs for detail.")
 		}
@@ -339,12 +670,28 @@ func (b *TelegramNotifier) Launch() error {
 		}
 	})
 
+	b.Handle("/feeds", func(m *telegram.Message) {
+		err := b.replyFeeds(m)
+		if err != nil {
+			errChan <- err
+		}
+	})
+
 	b.Handle("/unsubscribe", func(m *telegram.Message) {
-		err := b.removeListener(m.Chat.ID)
+		names, _, err := b.selectFeeds(m.Chat.ID, m.Payload, false)
+		if err != nil {
+			err = b.replyFeedError(m, err)
+			if err != nil {
+				errChan <- err
+			}
+			return
+		}
+
+		err = b.removeListener(m.Chat.ID, names[0])
 
 		switch err.(type) {
 		case nil:
-			_, err = b.Send(m.Sender, "You are no longer receiving update")
+			_, err = b.Send(m.Sender, fmt.Sprintf("You are no longer receiving the updates of %s", names[0]))
 		case KeyNotFoundError:
 			_, err = b.Send(m.Sender, "You are not registered yet")
 		}
@@ -370,25 +717,40 @@ func (b *TelegramNotifier) Launch() error {
 		b.Stop()
 	})
 
-	b.Handle("/touch", func(m *telegram.Message) {
-		err := b.replyFetchedPosts(m, b.redditBot.Touch)
-		if err != nil {
-			errChan <- err
-		}
-	})
-
-	updateHandle := func(m *telegram.Message) {
-		err := b.replyFetchedPosts(m, b.redditBot.Update)
-		if err != nil {
-			errChan <- err
+	// fetchHandle replies with the posts fetched by `fetcher` on the feed
+	// named in the payload, or on every feed of the chat
+	fetchHandle := func(fetcher feedFetcher, dedupe bool) func(*telegram.Message) {
+		return func(m *telegram.Message) {
+			names, _, err := b.selectFeeds(m.Chat.ID, m.Payload, true)
+			if err != nil {
+				err = b.replyFeedError(m, err)
+			} else {
+				err = b.replyFetchedPosts(m, names, fetcher, dedupe)
+			}
+			if err != nil {
+				errChan <- err
+			}
 		}
 	}
 
+	b.Handle("/touch", fetchHandle(b.touchShared, true))
+
+	updateHandle := fetchHandle(b.updateShared, true)
+
 	b.Handle("/update", updateHandle)
 	b.Handle("/up", updateHandle)
 
 	b.Handle("/grow", func(m *telegram.Message) {
-		size, err := strconv.Atoi(m.Payload)
+		names, payload, err := b.selectFeeds(m.Chat.ID, m.Payload, true)
+		if err != nil {
+			err = b.replyFeedError(m, err)
+			if err != nil {
+				errChan <- err
+			}
+			return
+		}
+
+		size, err := strconv.Atoi(payload)
 		if err != nil || size < 1 {
 			_, err := b.Send(m.Sender, "/grow requires positive size")
 			if err != nil {
@@ -397,9 +759,29 @@ func (b *TelegramNotifier) Launch() error {
 			return
 		}
 
-		err = b.replyFetchedPosts(m, func(f *reddit.Feed) ([]*reddit.Post, error) {
-			return b.redditBot.UpdateForAnchorSize(f, size)
-		})
+		// the anchor of a shared feed is the one of all its subscribers
+		var grown []string
+		for _, name := range names {
+			shared, err := b.sharedWithOthers(m.Chat.ID, name)
+			if err != nil {
+				b.Send(m.Sender, "Unable to read your feeds, see logs for detail.")
+				errChan <- err
+				return
+			}
+			if shared {
+				_, err = b.Send(m.Sender, fmt.Sprintf("The feed %s shares its requests to reddit with other chats, its anchor size can't be changed.", name))
+				if err != nil {
+					errChan <- err
+				}
+				continue
+			}
+			grown = append(grown, name)
+		}
+		if len(grown) == 0 {
+			return
+		}
+
+		err = b.replyFetchedPosts(m, grown, b.growShared(size), true)
 		if err != nil {
 			errChan <- err
 		}
@@ -410,15 +792,87 @@ func (b *TelegramNotifier) Launch() error {
 		}
 	})
 
-	b.Handle("/peek", func(m *telegram.Message) {
-		err := b.replyFetchedPosts(m, b.redditBot.Peek)
+	b.Handle("/peek", fetchHandle(b.peekShared, false))
+
+	b.Handle("/poll", func(m *telegram.Message) {
+		names, payload, err := b.selectFeeds(m.Chat.ID, m.Payload, false)
+		if err != nil {
+			err = b.replyFeedError(m, err)
+			if err != nil {
+				errChan <- err
+			}
+			return
+		}
+
+		window, err := parseDuration(payload)
+		if err != nil || window <= 0 || window > MaxPollWindow {
+			_, err := b.Send(m.Sender, fmt.Sprintf("/poll requires a positive duration up to %v (e.g. 6h, 2d)", MaxPollWindow))
+			if err != nil {
+				errChan <- err
+			}
+			return
+		}
+
+		err = b.replyPoll(m, names[0], window)
 		if err != nil {
 			errChan <- err
 		}
 	})
 
-	b.Handle("/poll", func(m *telegram.Message) {
-		_, err := b.Send(m.Sender, "unsupported yet")
+	b.Handle("/interval", func(m *telegram.Message) {
+		names, payload, err := b.selectFeeds(m.Chat.ID, m.Payload, false, "default")
+		if err != nil {
+			err = b.replyFeedError(m, err)
+			if err != nil {
+				errChan <- err
+			}
+			return
+		}
+
+		var interval time.Duration
+		if payload != "default" {
+			interval, err = parseDuration(payload)
+		}
+		if err != nil || interval < 0 {
+			_, err := b.Send(m.Sender, "/interval requires a duration (e.g. 30m, 2h) or 'default'")
+			if err != nil {
+				errChan <- err
+			}
+			return
+		}
+
+		err = b.setInterval(m.Chat.ID, names[0], interval)
+		switch err.(type) {
+		case nil:
+			if interval == 0 {
+				interval = b.PollInterval
+			}
+			_, err = b.Send(m.Sender, fmt.Sprintf("Your feed %s will be updated every %v", names[0], interval))
+		case KeyNotFoundError:
+			_, err = b.Send(m.Sender, "You are not subscribed to any feed.")
+		default:
+			_, err = b.Send(m.Sender, fmt.Sprintf("Unable to set the interval: %v", err))
+		}
+
+		if err != nil {
+			errChan <- err
+		}
+	})
+
+	b.Handle("/budget", func(m *telegram.Message) {
+		message := "The reddit bot has no rate limit."
+		if limited, ok := b.redditBot.(interface{ Budget() reddit.Budget }); ok {
+			budget := limited.Budget()
+			message = fmt.Sprintf(
+				"%d/%d requests remaining until %s, %d waiting.",
+				budget.Remaining,
+				budget.Limit,
+				budget.Reset.Local().Format(time.Kitchen),
+				budget.Waiting,
+			)
+		}
+
+		_, err := b.Send(m.Sender, message)
 		if err != nil {
 			errChan <- err
 		}
@@ -436,6 +890,17 @@ func (b *TelegramNotifier) Launch() error {
 
 	b.Handle("/clearall", func(m *telegram.Message) {
 		b.db.Update(func(t *bolt.Tx) error {
+			for _, name := range []string{seenBucketName, openBucketName, sharedBucketName, deadLetterBucketName} {
+				err := t.DeleteBucket([]byte(name))
+				if err != nil {
+					return err
+				}
+				_, err = t.CreateBucket([]byte(name))
+				if err != nil {
+					return err
+				}
+			}
+
 			bucket := t.Bucket([]byte(bucketName))
 			return bucket.ForEach(func(k, v []byte) error {
 				return bucket.Delete(k)
@@ -443,10 +908,147 @@ func (b *TelegramNotifier) Launch() error {
 		})
 	})
 
+	b.Handle("/open", func(m *telegram.Message) {
+		err := b.replyOpen(m)
+		if err != nil {
+			errChan <- err
+		}
+	})
+
+	b.Handle("/reminder", func(m *telegram.Message) {
+		var delay time.Duration
+		var err error
+		switch m.Payload {
+		case "default":
+		case "off":
+			delay = -1
+		default:
+			delay, err = parseDuration(m.Payload)
+			if err == nil && delay <= 0 {
+				err = fmt.Errorf("non positive delay")
+			}
+		}
+		if err != nil {
+			_, err := b.Send(m.Sender, "/reminder requires a delay before the deadline (e.g. 2h, 1d), 'default' or 'off'")
+			if err != nil {
+				errChan <- err
+			}
+			return
+		}
+
+		err = b.updateSettings(m.Chat.ID, func(settings *chatSettings) error {
+			settings.Reminder = delay
+			return nil
+		})
+		if err != nil {
+			b.Send(m.Sender, "Unable to save the setting, see logs for detail.")
+			errChan <- err
+			return
+		}
+
+		message := "Reminders are disabled."
+		if delay >= 0 {
+			if delay == 0 {
+				delay = DefaultReminder
+			}
+			message = fmt.Sprintf("You will be reminded %s before the end of the giveaways.", formatDelay(delay))
+		}
+
+		_, err = b.Send(m.Sender, message)
+		if err != nil {
+			errChan <- err
+		}
+	})
+
+	b.Handle("/filter", func(m *telegram.Message) {
+		err := b.replyFilter(m)
+		if err != nil {
+			errChan <- err
+		}
+	})
+
+	b.Handle("/sink", func(m *telegram.Message) {
+		err := b.replySink(m)
+		if err != nil {
+			errChan <- err
+		}
+	})
+
+	b.Handle("/region", func(m *telegram.Message) {
+		err := b.replyRegion(m)
+		if err != nil {
+			errChan <- err
+		}
+	})
+
+	b.Handle(&labelButton, func(c *telegram.Callback) {
+		err := b.replyLabel(c)
+		if err != nil {
+			errChan <- err
+		}
+	})
+
+	b.Handle("/seen", func(m *telegram.Message) {
+		var err error
+		switch m.Payload {
+		case "":
+			err = b.replySeen(m)
+		case "reset":
+			err = b.resetSeen(m.Chat.ID)
+			if err == nil {
+				_, err = b.Send(m.Sender, "The sent posts are forgotten, they may be sent again.")
+			}
+		default:
+			_, err = b.Send(m.Sender, "usage: /seen to list the posts already sent, /seen reset to forget them")
+		}
+
+		if err != nil {
+			errChan <- err
+		}
+	})
+
+	b.Handle("/deadletters", func(m *telegram.Message) {
+		var err error
+		switch m.Payload {
+		case "":
+			err = b.replyDeadLetters(m)
+		case "clear":
+			err = b.db.Update(func(t *bolt.Tx) error {
+				return deleteDeadLetters(t, m.Chat.ID)
+			})
+			if err == nil {
+				_, err = b.Send(m.Sender, "The failed deliveries are forgotten.")
+			}
+		default:
+			_, err = b.Send(m.Sender, "usage: /deadletters to list the failed deliveries of the webhooks, /deadletters clear to forget them")
+		}
+
+		if err != nil {
+			errChan <- err
+		}
+	})
+
 	b.Handle("/setstate", func(m *telegram.Message) {
+		// "[name] <subscription as JSON>"
+		name, state := DefaultFeedName, strings.TrimSpace(m.Payload)
+		if !strings.HasPrefix(state, "{") {
+			parts := strings.SplitN(state, " ", 2)
+			name = strings.ToLower(parts[0])
+			state = ""
+			if len(parts) == 2 {
+				state = parts[1]
+			}
+		}
+		if !feedNameRx.MatchString(name) {
+			_, err := b.Send(m.Sender, fmt.Sprintf("%q is not a valid feed name", name))
+			if err != nil {
+				errChan <- err
+			}
+			return
+		}
 
-		var feed reddit.Feed
-		err := json.Unmarshal([]byte(m.Payload), &feed)
+		var sub subscription
+		err := json.Unmarshal([]byte(state), &sub)
 		if err != nil {
 			_, err = b.Send(m.Sender, fmt.Sprintf("Unable to deserialize Feed: %v", err))
 			if err != nil {
@@ -455,20 +1057,61 @@ func (b *TelegramNotifier) Launch() error {
 			return
 		}
 
-		data, err := json.Marshal(feed)
+		if len(sub.Subreddits) == 0 {
+			_, err = b.Send(m.Sender, "The feed must listen to at least one subreddit.")
+			if err != nil {
+				errChan <- err
+			}
+			return
+		}
+		if problem := b.checkSinks(&sub); len(problem) > 0 {
+			_, err = b.Send(m.Sender, problem)
+			if err != nil {
+				errChan <- err
+			}
+			return
+		}
+
+		// the feed starts at the current post of its shared feed, as a new
+		// subscription does
+		ctx, cancel := b.operationContext()
+		err = b.joinShared(ctx, &sub)
+		cancel()
 		if err != nil {
-			_, _ = b.Send(m.Sender, "Unable to re-serialize feed, see logs")
+			b.Send(m.Sender, "Internal error, please re-try later (is your internet connection ok?)")
 			errChan <- err
 			return
 		}
 
 		err = b.db.Update(func(t *bolt.Tx) error {
-			key := make([]byte, 8)
-			binary.BigEndian.PutUint64(key, uint64(m.Chat.ID))
-
 			bucket := t.Bucket([]byte(bucketName))
+			key := feedKey(m.Chat.ID, name)
+
+			var previous *subscription
+			if data := bucket.Get(key); data != nil {
+				err := json.Unmarshal(data, &previous)
+				if err != nil {
+					return err
+				}
+			}
 
-			return bucket.Put(key, data)
+			// the ongoing fetches of the feed must not overwrite it
+			var err error
+			sub.Version, err = bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			data, err := json.Marshal(sub)
+			if err != nil {
+				return err
+			}
+
+			err = bucket.Put(key, data)
+			if err != nil || previous == nil {
+				return err
+			}
+			return leaveShared(t, previous.Subreddits)
 		})
 
 		if err != nil {
@@ -483,14 +1126,40 @@ func (b *TelegramNotifier) Launch() error {
 		}
 	})
 
-	go b.Start()
-	b.started = true
+	// a webhook left by a previous run would prevent long polling
+	webhook, _ := b.Poller.(*Webhook)
+	if webhook != nil {
+		err := webhook.start(b.Bot)
+		if err != nil {
+			return err
+		}
+	} else if err := b.RemoveWebhook(); err != nil {
+		return err
+	}
+
+	b.startMutex.Lock()
+	select {
+	case <-b.done:
+		// stopped before listening
+	default:
+		go b.Start()
+		b.started = true
+	}
+	b.startMutex.Unlock()
+
+	b.scheduler.Add(1)
+	go b.schedule()
 
 	for {
 		select {
 		case err := <-errChan:
 			log.Println(err)
 		case <-b.done:
+			// wait for the ongoing scheduled update
+			b.scheduler.Wait()
+			if webhook != nil {
+				return webhook.remove(b.Bot)
+			}
 			return nil
 		}
 	}
@@ -0,0 +1,204 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
+	"github.com/maxime915/mk-giveaway-notifier/sink"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBotAPI is a local stand-in for the Bot API, it serves the updates
+// queued by `send` to the long poller and records the messages of the bot
+type fakeBotAPI struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	updates  []map[string]interface{}
+	lastID   int
+	messages chan string
+}
+
+func newFakeBotAPI(t *testing.T, token string) *fakeBotAPI {
+	api := &fakeBotAPI{messages: make(chan string, 10)}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := "/bot" + token + "/"
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"ok": false, "error_code": 401, "description": "Unauthorized"}`))
+			return
+		}
+
+		var params map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&params)
+
+		var result interface{} = true
+		switch strings.TrimPrefix(r.URL.Path, prefix) {
+		case "getMe":
+			result = map[string]interface{}{"id": 1, "is_bot": true, "first_name": "Notifier", "username": "notifier_bot"}
+		case "getUpdates":
+			api.mutex.Lock()
+			result = api.updates
+			api.updates = nil
+			api.mutex.Unlock()
+			// hold the request briefly as a long poll does
+			time.Sleep(10 * time.Millisecond)
+		case "sendMessage":
+			api.messages <- params["text"].(string)
+			result = map[string]interface{}{"message_id": 1, "date": 0, "chat": map[string]interface{}{"id": 7, "type": "private"}}
+		case "sendChatAction", "deleteWebhook":
+		default:
+			t.Errorf("unexpected call to %s", r.URL.Path)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+	}))
+	return api
+}
+
+// send queues a message from the user 7 in its private chat
+func (api *fakeBotAPI) send(text string) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	api.lastID++
+	api.updates = append(api.updates, map[string]interface{}{
+		"update_id": api.lastID,
+		"message": map[string]interface{}{
+			"message_id": api.lastID,
+			"date":       time.Now().Unix(),
+			"text":       text,
+			"from":       map[string]interface{}{"id": 7, "first_name": "User"},
+			"chat":       map[string]interface{}{"id": 7, "type": "private"},
+		},
+	})
+}
+
+// receive returns the next message sent by the bot
+func (api *fakeBotAPI) receive(t *testing.T) string {
+	select {
+	case message := <-api.messages:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message from the bot")
+		return ""
+	}
+}
+
+// pending returns the messages sent by the bot before it answers a /ping
+func (api *fakeBotAPI) pending(t *testing.T) []string {
+	api.send("/ping")
+	var messages []string
+	for {
+		message := api.receive(t)
+		if message == "Hello World!" {
+			return messages
+		}
+		messages = append(messages, message)
+	}
+}
+
+// launchWithFakeBotAPI launches a bot against a fakeBotAPI, the returned
+// function stops it
+func launchWithFakeBotAPI(t *testing.T) (*fakeBotAPI, *TelegramNotifier, func()) {
+	return launchWithFetcher(t, newTestFakeBot(), nil)
+}
+
+// launchWithFetcher is like launchWithFakeBotAPI with the posts of `fetcher`,
+// `setup` (if not nil) is called before the bot is launched
+func launchWithFetcher(t *testing.T, fetcher reddit.Fetcher, setup func(*TelegramNotifier)) (*fakeBotAPI, *TelegramNotifier, func()) {
+	api := newFakeBotAPI(t, "123:token")
+
+	b, err := NewTelegramNotifierWithOptions("123:token", filepath.Join(t.TempDir(), "bot.db"), fetcher, Options{
+		URL:    api.URL + "/",
+		Client: &http.Client{Timeout: time.Second},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "notifier_bot", b.Me.Username)
+	if setup != nil {
+		setup(b)
+	}
+
+	done := make(chan error)
+	go func() { done <- b.Launch() }()
+
+	return api, b, func() {
+		b.Stop()
+		assert.Nil(t, <-done)
+		b.db.Close()
+		api.Close()
+	}
+}
+
+func TestLaunchWithLocalBotAPI(t *testing.T) {
+	_, err := NewTelegramNotifierWithOptions("123:token", filepath.Join(t.TempDir(), "bot.db"), newTestFakeBot(), Options{URL: "ftp://localhost"})
+	assert.NotNil(t, err)
+
+	api, _, stop := launchWithFakeBotAPI(t)
+	defer stop()
+
+	api.send("/ping")
+	assert.Equal(t, "Hello World!", api.receive(t))
+
+	api.send("/subscribe trades MechanicalKeyboards")
+	assert.Equal(t, "Noted, your feed trades now listens on r/MechanicalKeyboards.", api.receive(t))
+}
+
+func TestFilterReplyEscapesUserText(t *testing.T) {
+	api, _, stop := launchWithFakeBotAPI(t)
+	defer stop()
+
+	api.send("/subscribe")
+	api.receive(t)
+
+	api.send("/filter self_text contains \"<b>\"")
+	reply := api.receive(t)
+	assert.True(t, strings.HasPrefix(reply, "Invalid filter: unknown predicate &#34;self_text&#34;"), reply)
+	assert.Contains(t, reply, "<pre>self_text contains &#34;&lt;b&gt;&#34;\n^</pre>")
+
+	api.send("/filter title contains \"`*_\"")
+	assert.Equal(t, "Only the posts matching <code>title contains &#34;`*_&#34;</code> will be sent.", api.receive(t))
+}
+
+func TestSetStateChecksSinksAndCursor(t *testing.T) {
+	api, b, stop := launchWithFakeBotAPI(t)
+	defer stop()
+
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hook.Close()
+	state := func(sinks string) string {
+		return fmt.Sprintf(`/setstate {"url": "MechanicalKeyboards", "cursor": 999, "sinks": [%s]}`, sinks)
+	}
+
+	// the sinks are checked as by /sink add
+	api.send(state(`{"type": "webhook", "params": {"url": "` + hook.URL + `"}}`))
+	assert.Equal(t, "Invalid sink 1: invalid parameters for the webhook sink: secret is required", api.receive(t))
+
+	sink.AllowPrivateNetworks = false
+	api.send(state(`{"type": "webhook", "params": {"url": "` + hook.URL + `", "secret": "s"}}`))
+	reply := api.receive(t)
+	sink.AllowPrivateNetworks = true
+	assert.Contains(t, reply, "is not a public address")
+	_, err := b.subscription(7, DefaultFeedName)
+	assert.IsType(t, KeyNotFoundError{}, err)
+
+	// the cursor is the one of the shared feed
+	api.send(state(`{"type": "webhook", "params": {"url": "` + hook.URL + `", "secret": "s"}}`))
+	assert.Equal(t, "State correctly set without issue!", api.receive(t))
+	sub, err := b.subscription(7, DefaultFeedName)
+	assert.Nil(t, err)
+	assert.Len(t, sub.Sinks, 1)
+	assert.NotEqual(t, uint64(999), sub.Cursor)
+	shared, err := b.refreshShared(context.Background(), sub.Subreddits, 0, false)
+	assert.Nil(t, err)
+	assert.Equal(t, shared.Seq, sub.Cursor)
+}
@@ -1,7 +1,13 @@
 package telegram
 
 import (
+	"encoding/binary"
+	"fmt"
 	"strings"
+	"time"
+
+	"github.com/maxime915/mk-giveaway-notifier/duration"
+	"github.com/maxime915/mk-giveaway-notifier/reddit"
 )
 
 type baseError struct{}
@@ -12,6 +18,68 @@ type KeyExistError struct{ baseError }
 
 type KeyNotFoundError struct{ baseError }
 
-func isGiveaway(title string) bool {
-	return strings.Contains(strings.ToLower(title), "giveaway")
+// AmbiguousFeedError is returned when a command doesn't name the feed of a
+// chat having several feeds
+type AmbiguousFeedError struct{ baseError }
+
+// ConflictError is returned when a value of the database was modified
+// between the moment it was read and the moment it is written back
+type ConflictError struct{ baseError }
+
+// maxConflictRetries is the number of times an operation is retried after a
+// ConflictError
+const maxConflictRetries = 5
+
+// retryOnConflict calls `attempt` until it returns something else than a
+// ConflictError, at most 1+maxConflictRetries times.
+// Values read in a transaction and written back in another one, after calling
+// reddit, hold a version which is checked before writing them: the database
+// isn't locked during the request, and the operation starts over if another
+// one wrote the value in the meantime.
+func retryOnConflict(attempt func() error) error {
+	for retries := 0; ; retries++ {
+		err := attempt()
+		if _, ok := err.(ConflictError); !ok || retries == maxConflictRetries {
+			return err
+		}
+	}
+}
+
+// chatKey returns the key of a chat in the database
+func chatKey(chatID int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(chatID))
+	return key
+}
+
+// parseDuration parses a duration as time.ParseDuration does, with the
+// addition of days and weeks (e.g. "2d", "1w"), ignoring surrounding spaces.
+func parseDuration(s string) (time.Duration, error) {
+	return duration.Parse(strings.TrimSpace(s))
+}
+
+// describeRequestError returns a message explaining a failed request to reddit
+func describeRequestError(err *reddit.RequestError) string {
+	if err.Temporary() {
+		return fmt.Sprintf("Reddit is unavailable right now (%d attempts), try again later.", err.Attempts)
+	}
+	if err.NotFound() {
+		return "Reddit refused the request : the subreddit may be private, banned or missing."
+	}
+	return fmt.Sprintf("Reddit refused the request: %v", err.Err)
+}
+
+// describeSubredditError returns a message explaining why a subreddit can't be
+// subscribed to
+func describeSubredditError(err *reddit.SubredditError) string {
+	switch err.Reason {
+	case reddit.SubredditInvalid:
+		return fmt.Sprintf("%q is not a valid subreddit name (3 to 21 letters, digits or underscores).", err.Name)
+	case reddit.SubredditNotFound:
+		return fmt.Sprintf("r/%s does not exist, check the spelling.", err.Name)
+	case reddit.SubredditBanned:
+		return fmt.Sprintf("r/%s is banned.", err.Name)
+	default:
+		return fmt.Sprintf("r/%s is %s, the bot can't read it.", err.Name, err.Reason)
+	}
 }
@@ -0,0 +1,35 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"6h":     6 * time.Hour,
+		"1h30m":  90 * time.Minute,
+		"2d":     48 * time.Hour,
+		" 1w ":   7 * 24 * time.Hour,
+		"1.5d":   36 * time.Hour,
+		"45m":    45 * time.Minute,
+		"0.5w":   84 * time.Hour,
+		"90s":    90 * time.Second,
+		"1h2m3s": time.Hour + 2*time.Minute + 3*time.Second,
+	}
+
+	for input, expected := range cases {
+		d, err := parseDuration(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, d, input)
+	}
+}
+
+func TestParseDurationInvalid(t *testing.T) {
+	for _, input := range []string{"", "d", "2x", "twod", "2 d"} {
+		_, err := parseDuration(input)
+		assert.Error(t, err, input)
+	}
+}
@@ -0,0 +1,185 @@
+package telegram
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	telegram "gopkg.in/tucnak/telebot.v2"
+)
+
+// secretTokenHeader is the header in which Telegram sends the secret token of
+// the webhook with each update
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// the characters and length allowed by Telegram for the secret token
+var secretTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// webhookShutdownTimeout is the time left to the requests in progress to
+// finish when the bot stops
+const webhookShutdownTimeout = 10 * time.Second
+
+// Webhook is a telegram.Poller receiving the updates through an HTTP(S)
+// listener instead of long polling (see https://core.telegram.org/bots/api#setwebhook).
+// The webhook is registered with Telegram by Launch and removed when the bot
+// stops. The requests which don't carry the secret token are rejected.
+type Webhook struct {
+	// Listen is the address of the listener, e.g. ":8443"
+	Listen string
+	// PublicURL is the HTTPS URL at which Telegram reaches the listener, e.g.
+	// "https://bot.example.com/telegram", its path is served by the listener
+	PublicURL string
+	// SecretToken is sent by Telegram with each update, a random token is
+	// generated if it is empty
+	SecretToken string
+	// TLSCert and TLSKey are the paths of the certificate and key of the
+	// listener, plain HTTP is served if they are empty (e.g. behind a reverse
+	// proxy). The certificate must be trusted by Telegram as it isn't uploaded.
+	TLSCert string
+	TLSKey  string
+
+	path     string
+	listener net.Listener
+	server   *http.Server
+}
+
+// check returns an error if the settings of the webhook are invalid
+func (w *Webhook) check() error {
+	u, err := url.Parse(w.PublicURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || len(u.Host) == 0 {
+		return fmt.Errorf("%q is not an HTTPS URL", w.PublicURL)
+	}
+	if len(w.Listen) == 0 {
+		return fmt.Errorf("the webhook needs an address to listen on")
+	}
+	if len(w.SecretToken) > 0 && !secretTokenPattern.MatchString(w.SecretToken) {
+		return fmt.Errorf("the secret token must have 1 to 256 characters among A-Z, a-z, 0-9, _ and -")
+	}
+	if (len(w.TLSCert) == 0) != (len(w.TLSKey) == 0) {
+		return fmt.Errorf("the certificate and the key of the webhook go together")
+	}
+	return nil
+}
+
+// start opens the listener and registers the webhook with Telegram, the
+// updates are accepted once the bot starts polling
+func (w *Webhook) start(bot *telegram.Bot) error {
+	err := w.check()
+	if err != nil {
+		return err
+	}
+
+	if len(w.SecretToken) == 0 {
+		token := make([]byte, 32)
+		if _, err := rand.Read(token); err != nil {
+			return err
+		}
+		w.SecretToken = hex.EncodeToString(token)
+	}
+
+	u, _ := url.Parse(w.PublicURL)
+	w.path = u.Path
+	if len(w.path) == 0 {
+		w.path = "/"
+	}
+
+	w.server = &http.Server{}
+	if len(w.TLSCert) > 0 {
+		certificate, err := tls.LoadX509KeyPair(w.TLSCert, w.TLSKey)
+		if err != nil {
+			return err
+		}
+		w.server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{certificate}}
+	}
+
+	w.listener, err = net.Listen("tcp", w.Listen)
+	if err != nil {
+		return err
+	}
+
+	_, err = bot.Raw("setWebhook", map[string]string{
+		"url":          w.PublicURL,
+		"secret_token": w.SecretToken,
+	})
+	if err != nil {
+		w.listener.Close()
+		return fmt.Errorf("unable to register the webhook: %w", err)
+	}
+	return nil
+}
+
+// remove unregisters the webhook, the updates received in the meantime wait
+// for the next start of the bot
+func (w *Webhook) remove(bot *telegram.Bot) error {
+	return bot.RemoveWebhook()
+}
+
+// Poll serves the updates posted by Telegram until `stop` is closed
+func (w *Webhook) Poll(b *telegram.Bot, dest chan telegram.Update, stop chan struct{}) {
+	w.server.Handler = w.handler(dest, stop)
+
+	go func() {
+		var err error
+		if w.server.TLSConfig != nil {
+			err = w.server.ServeTLS(w.listener, "", "")
+		} else {
+			err = w.server.Serve(w.listener)
+		}
+		if err != http.ErrServerClosed {
+			log.Printf("webhook listener: %s\n", err.Error())
+		}
+	}()
+
+	<-stop
+	ctx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+	defer cancel()
+	if err := w.server.Shutdown(ctx); err != nil {
+		log.Printf("webhook shutdown: %s\n", err.Error())
+	}
+}
+
+// handler checks the secret token of the requests and passes their update to
+// `dest`
+func (w *Webhook) handler(dest chan<- telegram.Update, stop <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != w.path {
+			http.NotFound(rw, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		token := r.Header.Get(secretTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(w.SecretToken)) != 1 {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var update telegram.Update
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case dest <- update:
+		case <-stop:
+			// Telegram sends the update again later
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+}
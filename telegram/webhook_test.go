@@ -0,0 +1,68 @@
+package telegram
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	telegram "gopkg.in/tucnak/telebot.v2"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	w := &Webhook{SecretToken: "secret", path: "/telegram"}
+	dest := make(chan telegram.Update, 1)
+	stop := make(chan struct{})
+	handler := w.handler(dest, stop)
+
+	post := func(path, token, body string) int {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if len(token) > 0 {
+			r.Header.Set(secretTokenHeader, token)
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, r)
+		return rw.Code
+	}
+	update := `{"update_id": 42, "message": {"message_id": 1, "text": "/ping", "chat": {"id": 7}}}`
+
+	assert.Equal(t, http.StatusUnauthorized, post("/telegram", "", update))
+	assert.Equal(t, http.StatusUnauthorized, post("/telegram", "wrong", update))
+	assert.Equal(t, http.StatusNotFound, post("/other", "secret", update))
+	assert.Equal(t, http.StatusBadRequest, post("/telegram", "secret", "{"))
+	assert.Len(t, dest, 0)
+
+	assert.Equal(t, http.StatusOK, post("/telegram", "secret", update))
+	received := <-dest
+	assert.Equal(t, 42, received.ID)
+	assert.Equal(t, "/ping", received.Message.Text)
+
+	// once stopped, the updates are left to Telegram
+	dest <- received
+	close(stop)
+	assert.Equal(t, http.StatusServiceUnavailable, post("/telegram", "secret", update))
+}
+
+func TestWebhookCheck(t *testing.T) {
+	valid := Webhook{Listen: ":8443", PublicURL: "https://bot.example.com/telegram"}
+	assert.Nil(t, valid.check())
+
+	w := valid
+	w.PublicURL = "http://bot.example.com/telegram"
+	assert.NotNil(t, w.check())
+
+	w = valid
+	w.Listen = ""
+	assert.NotNil(t, w.check())
+
+	w = valid
+	w.SecretToken = "not so secret"
+	assert.NotNil(t, w.check())
+
+	w = valid
+	w.TLSCert = "cert.pem"
+	assert.NotNil(t, w.check())
+	w.TLSKey = "key.pem"
+	assert.Nil(t, w.check())
+}